@@ -0,0 +1,560 @@
+package bolted
+
+import (
+	"context"
+	"errors"
+	"io"
+	"iter"
+
+	"github.com/draganm/bolted/dbpath"
+	"go.etcd.io/bbolt"
+)
+
+var ErrPermissionDenied = errors.New("permission denied")
+
+func IsPermissionDenied(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	return errors.Is(err, ErrPermissionDenied)
+}
+
+// Permission is a set of operations allowed on a path.
+type Permission uint8
+
+const (
+	// PermissionRead allows reading values, iterating and finding paths.
+	PermissionRead Permission = 1 << iota
+	// PermissionWrite allows creating maps and putting values.
+	PermissionWrite
+	// PermissionDelete allows deleting values and maps, including all their children.
+	PermissionDelete
+
+	PermissionAll = PermissionRead | PermissionWrite | PermissionDelete
+)
+
+// AnyPrincipal used as principal of an ACLRule applies the rule to all principals, including the anonymous one.
+const AnyPrincipal = "*"
+
+// ACLRule grants permissions on paths matched by Matcher to Principal.
+type ACLRule struct {
+	Principal   string
+	Matcher     dbpath.Matcher
+	Permissions Permission
+}
+
+// ACL grants permissions to principals. Permissions granted by all rules matching a path are combined,
+// everything not granted is denied.
+type ACL struct {
+	Rules []ACLRule
+	// Principal extracts the principal from the context of a transaction.
+	// When nil, the principal set by WithPrincipal is used.
+	// The principal is passed on to the wrapped database with WithPrincipal, so the audit log records it.
+	Principal func(ctx context.Context) string
+}
+
+type principalContextKey struct{}
+
+// WithPrincipal returns a context identifying principal to databases wrapped by WithACL.
+func WithPrincipal(ctx context.Context, principal string) context.Context {
+	return context.WithValue(ctx, principalContextKey{}, principal)
+}
+
+// PrincipalFromContext returns the principal set by WithPrincipal, or an empty string if there is none.
+func PrincipalFromContext(ctx context.Context) string {
+	principal, _ := ctx.Value(principalContextKey{}).(string)
+	return principal
+}
+
+func (a ACL) principal(ctx context.Context) string {
+	if a.Principal != nil {
+		return a.Principal(ctx)
+	}
+	return PrincipalFromContext(ctx)
+}
+
+// withPrincipal returns the principal of ctx and the context passing it on to the wrapped database.
+func (a ACL) withPrincipal(ctx context.Context) (context.Context, string) {
+	principal := a.principal(ctx)
+	return WithPrincipal(ctx, principal), principal
+}
+
+// WithACL returns a Database enforcing acl for the principal of every transaction.
+// Operations on paths without the required permission fail with ErrPermissionDenied.
+// Iteration, pagination, walking, finding and observing hide paths the principal can't see.
+// A map is visible if it can be read, or if a rule could grant reading some of its children,
+// so principals can navigate to the subtrees they have access to.
+func WithACL(db Database, acl ACL) Database {
+	return &aclDB{db: db, acl: acl}
+}
+
+type aclDB struct {
+	db  Database
+	acl ACL
+}
+
+func (d *aclDB) Read(fn func(tx ReadTx) error) error {
+	return d.ReadWithContext(context.Background(), fn)
+}
+
+func (d *aclDB) ReadWithContext(ctx context.Context, fn func(tx ReadTx) error) error {
+	ctx, principal := d.acl.withPrincipal(ctx)
+	return d.db.ReadWithContext(ctx, func(tx ReadTx) error {
+		return fn(&aclTx{ReadTx: tx, wtx: notWritableTx{tx}, rules: d.acl.Rules, principal: principal})
+	})
+}
+
+func (d *aclDB) Write(fn func(tx WriteTx) error) error {
+	return d.WriteWithContext(context.Background(), fn)
+}
+
+func (d *aclDB) WriteWithContext(ctx context.Context, fn func(tx WriteTx) error) error {
+	ctx, principal := d.acl.withPrincipal(ctx)
+	return d.db.WriteWithContext(ctx, func(tx WriteTx) error {
+		return fn(&aclTx{ReadTx: tx, wtx: tx, rules: d.acl.Rules, principal: principal})
+	})
+}
+
+// Observe reports only changes of paths visible to the principal of ctx.
+func (d *aclDB) Observe(ctx context.Context, m dbpath.Matcher) <-chan ObservedChanges {
+	checker := aclChecker{rules: d.acl.Rules, principal: d.acl.principal(ctx)}
+	changes := d.db.Observe(ctx, m)
+	visibleChanges := make(chan ObservedChanges)
+
+	go func() {
+		defer close(visibleChanges)
+		first := true
+		for ch := range changes {
+			visible := ObservedChanges{}
+			for _, c := range ch {
+				if checker.visible(c.Path) {
+					visible = append(visible, c)
+				}
+			}
+
+			// the initial empty changes are always sent
+			if len(visible) == 0 && !first {
+				continue
+			}
+			first = false
+
+			select {
+			case visibleChanges <- visible:
+			case <-ctx.Done():
+				// drain changes until the underlying channel is closed
+				for range changes {
+				}
+				return
+			}
+		}
+	}()
+
+	return visibleChanges
+}
+
+func (d *aclDB) Sub(prefix dbpath.Path) Database {
+	return newSubDB(d, prefix)
+}
+
+func (d *aclDB) Close() error {
+	return d.db.Close()
+}
+
+func (d *aclDB) Stats() (*bbolt.Stats, error) {
+	return d.db.Stats()
+}
+
+type aclChecker struct {
+	rules     []ACLRule
+	principal string
+}
+
+func (c aclChecker) appliesTo(r ACLRule) bool {
+	return r.Principal == AnyPrincipal || r.Principal == c.principal
+}
+
+// allowed returns true if the permission on path is granted to the principal.
+func (c aclChecker) allowed(path dbpath.Path, permission Permission) bool {
+	for _, r := range c.rules {
+		if c.appliesTo(r) && r.Permissions&permission == permission && r.Matcher.Matches(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// visible returns true if path, or any path below it, could be read by the principal.
+func (c aclChecker) visible(path dbpath.Path) bool {
+	for _, r := range c.rules {
+		if !c.appliesTo(r) || r.Permissions&PermissionRead == 0 {
+			continue
+		}
+		state := r.Matcher.Start()
+		for _, e := range path {
+			state = state.Step(e)
+		}
+		if state.Matches() || !state.IsDead() {
+			return true
+		}
+	}
+	return false
+}
+
+// canSee returns true if a value at path can be read, or if a map at path is visible.
+func (c aclChecker) canSee(path dbpath.Path, isMap bool) bool {
+	if isMap {
+		return c.visible(path)
+	}
+	return c.allowed(path, PermissionRead)
+}
+
+// aclTx checks permissions of the principal before calling methods of the underlying transaction.
+// The embedded ReadTx provides ID, GetDBFileSize and Context.
+type aclTx struct {
+	ReadTx
+	// wtx is notWritableTx for read transactions
+	wtx       WriteTx
+	rules     []ACLRule
+	principal string
+}
+
+func (t *aclTx) checker() aclChecker {
+	return aclChecker{rules: t.rules, principal: t.principal}
+}
+
+// deniedKey returns the first path of parent's children with keys that is not allowed, or nil if all are allowed.
+func (t *aclTx) deniedKey(parent dbpath.Path, keys []string, permission Permission) dbpath.Path {
+	for _, k := range keys {
+		p := parent.Append(k)
+		if !t.checker().allowed(p, permission) {
+			return p
+		}
+	}
+	return nil
+}
+
+func (t *aclTx) CreateMap(path dbpath.Path) {
+	if !t.checker().allowed(path, PermissionWrite) {
+		raiseErrorForPath(path, "CreateMap", ErrPermissionDenied)
+	}
+	t.wtx.CreateMap(path)
+}
+
+func (t *aclTx) Delete(path dbpath.Path) {
+	if !t.checker().allowed(path, PermissionDelete) {
+		raiseErrorForPath(path, "Delete", ErrPermissionDenied)
+	}
+	t.wtx.Delete(path)
+}
+
+func (t *aclTx) Put(path dbpath.Path, value []byte) {
+	if !t.checker().allowed(path, PermissionWrite) {
+		raiseErrorForPath(path, "Put", ErrPermissionDenied)
+	}
+	t.wtx.Put(path, value)
+}
+
+func (t *aclTx) PutMany(parent dbpath.Path, kvs []KeyValue) {
+	keys := make([]string, len(kvs))
+	for i, kv := range kvs {
+		keys[i] = kv.Key
+	}
+	denied := t.deniedKey(parent, keys, PermissionWrite)
+	if denied != nil {
+		raiseErrorForPath(denied, "PutMany", ErrPermissionDenied)
+	}
+	t.wtx.PutMany(parent, kvs)
+}
+
+func (t *aclTx) PutReader(path dbpath.Path, r io.Reader) {
+	if !t.checker().allowed(path, PermissionWrite) {
+		raiseErrorForPath(path, "PutReader", ErrPermissionDenied)
+	}
+	t.wtx.PutReader(path, r)
+}
+
+func (t *aclTx) DeleteMany(parent dbpath.Path, keys []string) {
+	denied := t.deniedKey(parent, keys, PermissionDelete)
+	if denied != nil {
+		raiseErrorForPath(denied, "DeleteMany", ErrPermissionDenied)
+	}
+	t.wtx.DeleteMany(parent, keys)
+}
+
+func (t *aclTx) SetFillPercent(fillPercent float64) {
+	t.wtx.SetFillPercent(fillPercent)
+}
+
+func (t *aclTx) Get(path dbpath.Path) []byte {
+	if !t.checker().allowed(path, PermissionRead) {
+		raiseErrorForPath(path, "Get", ErrPermissionDenied)
+	}
+	return t.ReadTx.Get(path)
+}
+
+func (t *aclTx) View(path dbpath.Path, fn func(v []byte) error) error {
+	if !t.checker().allowed(path, PermissionRead) {
+		raiseErrorForPath(path, "View", ErrPermissionDenied)
+	}
+	return t.ReadTx.View(path, fn)
+}
+
+func (t *aclTx) GetInto(path dbpath.Path, buf []byte) []byte {
+	if !t.checker().allowed(path, PermissionRead) {
+		raiseErrorForPath(path, "GetInto", ErrPermissionDenied)
+	}
+	return t.ReadTx.GetInto(path, buf)
+}
+
+func (t *aclTx) GetMany(parent dbpath.Path, keys []string) [][]byte {
+	denied := t.deniedKey(parent, keys, PermissionRead)
+	if denied != nil {
+		raiseErrorForPath(denied, "GetMany", ErrPermissionDenied)
+	}
+	return t.ReadTx.GetMany(parent, keys)
+}
+
+func (t *aclTx) OpenReader(path dbpath.Path) io.ReadSeeker {
+	if !t.checker().allowed(path, PermissionRead) {
+		raiseErrorForPath(path, "OpenReader", ErrPermissionDenied)
+	}
+	return t.ReadTx.OpenReader(path)
+}
+
+func (t *aclTx) Iterate(path dbpath.Path) Iterator {
+	if !t.checker().visible(path) {
+		raiseErrorForPath(path, "Iterate", ErrPermissionDenied)
+	}
+	return t.newIterator(path, t.ReadTx.Iterate(path))
+}
+
+func (t *aclTx) IterateRange(path dbpath.Path, from, to Bound) Iterator {
+	if !t.checker().visible(path) {
+		raiseErrorForPath(path, "IterateRange", ErrPermissionDenied)
+	}
+	return t.newIterator(path, t.ReadTx.IterateRange(path, from, to))
+}
+
+func (t *aclTx) IterateRangeReverse(path dbpath.Path, from, to Bound) Iterator {
+	if !t.checker().visible(path) {
+		raiseErrorForPath(path, "IterateRangeReverse", ErrPermissionDenied)
+	}
+	return t.newIterator(path, t.ReadTx.IterateRangeReverse(path, from, to))
+}
+
+func (t *aclTx) IteratePrefix(path dbpath.Path, prefix string) Iterator {
+	if !t.checker().visible(path) {
+		raiseErrorForPath(path, "IteratePrefix", ErrPermissionDenied)
+	}
+	return t.newIterator(path, t.ReadTx.IteratePrefix(path, prefix))
+}
+
+func (t *aclTx) IteratePrefixReverse(path dbpath.Path, prefix string) Iterator {
+	if !t.checker().visible(path) {
+		raiseErrorForPath(path, "IteratePrefixReverse", ErrPermissionDenied)
+	}
+	return t.newIterator(path, t.ReadTx.IteratePrefixReverse(path, prefix))
+}
+
+// page fills the page with visible entries, fetching further pages of the underlying transaction
+// as long as entries are hidden.
+func (t *aclTx) page(path dbpath.Path, token string, limit int, pageFn func(path dbpath.Path, token string, limit int) PageResult) PageResult {
+	res := PageResult{
+		Entries: []PageEntry{},
+	}
+
+	for {
+		pr := pageFn(path, token, limit-len(res.Entries))
+		for _, e := range pr.Entries {
+			if t.checker().canSee(path.Append(e.Key), e.IsMap) {
+				res.Entries = append(res.Entries, e)
+			}
+		}
+		res.NextToken = pr.NextToken
+		if pr.NextToken == "" || len(res.Entries) == limit {
+			return res
+		}
+		token = pr.NextToken
+	}
+}
+
+func (t *aclTx) Page(path dbpath.Path, token string, limit int) PageResult {
+	if !t.checker().visible(path) {
+		raiseErrorForPath(path, "Page", ErrPermissionDenied)
+	}
+	return t.page(path, token, limit, t.ReadTx.Page)
+}
+
+func (t *aclTx) PageReverse(path dbpath.Path, token string, limit int) PageResult {
+	if !t.checker().visible(path) {
+		raiseErrorForPath(path, "PageReverse", ErrPermissionDenied)
+	}
+	return t.page(path, token, limit, t.ReadTx.PageReverse)
+}
+
+// visibleWalkFunc calls fn only for visible paths.
+// Maps without visible children are skipped when they are visited in pre-order.
+func (t *aclTx) visibleWalkFunc(fn WalkFunc) WalkFunc {
+	return func(path dbpath.Path, isMap bool, value []byte) error {
+		if t.checker().canSee(path, isMap) {
+			return fn(path, isMap, value)
+		}
+		if isMap {
+			return SkipMap
+		}
+		return nil
+	}
+}
+
+func (t *aclTx) Walk(root dbpath.Path, fn WalkFunc) error {
+	if !t.checker().visible(root) {
+		raiseErrorForPath(root, "Walk", ErrPermissionDenied)
+	}
+	return t.ReadTx.Walk(root, t.visibleWalkFunc(fn))
+}
+
+// WalkWithOptions hides paths the principal can't see.
+// Since the matcher of opts limits calls of the walk function, maps not matched by it aren't skipped.
+func (t *aclTx) WalkWithOptions(root dbpath.Path, opts WalkOptions, fn WalkFunc) error {
+	if !t.checker().visible(root) {
+		raiseErrorForPath(root, "WalkWithOptions", ErrPermissionDenied)
+	}
+	return t.ReadTx.WalkWithOptions(root, opts, t.visibleWalkFunc(fn))
+}
+
+func (t *aclTx) Find(m dbpath.Matcher) []dbpath.Path {
+	found := []dbpath.Path{}
+	err := t.ReadTx.FindWithFunc(m, func(path dbpath.Path, isMap bool) error {
+		if t.checker().canSee(path, isMap) {
+			found = append(found, path)
+		}
+		return nil
+	})
+	if err != nil {
+		raiseErrorForPath(dbpath.NilPath, "Find", err)
+	}
+	return found
+}
+
+func (t *aclTx) FindWithFunc(m dbpath.Matcher, fn FindFunc) error {
+	return t.ReadTx.FindWithFunc(m, func(path dbpath.Path, isMap bool) error {
+		if !t.checker().canSee(path, isMap) {
+			return nil
+		}
+		return fn(path, isMap)
+	})
+}
+
+func (t *aclTx) Exists(path dbpath.Path) bool {
+	if !t.checker().visible(path) {
+		raiseErrorForPath(path, "Exists", ErrPermissionDenied)
+	}
+	return t.ReadTx.Exists(path)
+}
+
+func (t *aclTx) IsMap(path dbpath.Path) bool {
+	if !t.checker().visible(path) {
+		raiseErrorForPath(path, "IsMap", ErrPermissionDenied)
+	}
+	return t.ReadTx.IsMap(path)
+}
+
+func (t *aclTx) GetSizeOf(path dbpath.Path) uint64 {
+	if !t.checker().allowed(path, PermissionRead) {
+		raiseErrorForPath(path, "GetSizeOf", ErrPermissionDenied)
+	}
+	return t.ReadTx.GetSizeOf(path)
+}
+
+// DumpDatabase requires the permission to read the root of the database.
+func (t *aclTx) DumpDatabase(w io.Writer) int64 {
+	if !t.checker().allowed(dbpath.NilPath, PermissionRead) {
+		raiseErrorForPath(dbpath.NilPath, "DumpDatabase", ErrPermissionDenied)
+	}
+	return t.ReadTx.DumpDatabase(w)
+}
+
+// aclIterator skips entries of the underlying iterator that are not visible to the principal.
+type aclIterator struct {
+	Iterator
+	tx   *aclTx
+	path dbpath.Path
+}
+
+func (t *aclTx) newIterator(path dbpath.Path, it Iterator) Iterator {
+	ai := &aclIterator{Iterator: it, tx: t, path: path}
+	ai.skip(it.Next)
+	return ai
+}
+
+func (i *aclIterator) visible() bool {
+	return i.tx.checker().canSee(i.path.Append(i.Iterator.GetKey()), i.Iterator.IsMap())
+}
+
+// skip moves the underlying iterator with move until it is done or at a visible entry.
+func (i *aclIterator) skip(move func()) {
+	for !i.Iterator.IsDone() && !i.visible() {
+		move()
+	}
+}
+
+func (i *aclIterator) Next() {
+	i.Iterator.Next()
+	i.skip(i.Iterator.Next)
+}
+
+func (i *aclIterator) Prev() {
+	i.Iterator.Prev()
+	i.skip(i.Iterator.Prev)
+}
+
+func (i *aclIterator) Seek(key string) {
+	i.Iterator.Seek(key)
+	i.skip(i.Iterator.Next)
+}
+
+func (i *aclIterator) First() {
+	i.Iterator.First()
+	i.skip(i.Iterator.Next)
+}
+
+func (i *aclIterator) Last() {
+	i.Iterator.Last()
+	i.skip(i.Iterator.Prev)
+}
+
+// All rewinds the iterator and yields keys and copies of values of all visible entries.
+// Value is nil for maps.
+func (i *aclIterator) All() iter.Seq2[string, []byte] {
+	return func(yield func(key string, value []byte) bool) {
+		for i.First(); !i.IsDone(); i.Next() {
+			if !yield(i.GetKey(), i.GetValue()) {
+				return
+			}
+		}
+	}
+}
+
+// Keys rewinds the iterator and yields keys of all visible entries.
+func (i *aclIterator) Keys() iter.Seq[string] {
+	return func(yield func(key string) bool) {
+		for i.First(); !i.IsDone(); i.Next() {
+			if !yield(i.GetKey()) {
+				return
+			}
+		}
+	}
+}
+
+// Values rewinds the iterator and yields copies of values of all visible entries.
+// Value is nil for maps.
+func (i *aclIterator) Values() iter.Seq[[]byte] {
+	return func(yield func(value []byte) bool) {
+		for i.First(); !i.IsDone(); i.Next() {
+			if !yield(i.GetValue()) {
+				return
+			}
+		}
+	}
+}
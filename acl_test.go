@@ -0,0 +1,242 @@
+package bolted_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/draganm/bolted"
+	"github.com/draganm/bolted/dbpath"
+	"github.com/stretchr/testify/require"
+)
+
+func TestACL(t *testing.T) {
+	db, cleanup := openMemoryDatabase(t, bolted.Options{})
+	defer cleanup()
+
+	err := db.Write(func(tx bolted.WriteTx) error {
+		tx.CreateMap(dbpath.ToPath("shared"))
+		tx.Put(dbpath.ToPath("shared", "motd"), []byte("hello"))
+		tx.CreateMap(dbpath.ToPath("tenants"))
+		for _, tenant := range []string{"alice", "bob", "carol"} {
+			tx.CreateMap(dbpath.ToPath("tenants", tenant))
+			tx.Put(dbpath.ToPath("tenants", tenant, "name"), []byte(tenant))
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	adb := bolted.WithACL(db, bolted.ACL{
+		Rules: []bolted.ACLRule{
+			{Principal: "alice", Matcher: dbpath.MustParseMatcher("tenants/alice/**"), Permissions: bolted.PermissionAll},
+			{Principal: "bob", Matcher: dbpath.MustParseMatcher("tenants/bob/**"), Permissions: bolted.PermissionRead | bolted.PermissionWrite},
+			{Principal: "carol", Matcher: dbpath.MustParseMatcher("tenants/carol/**"), Permissions: bolted.PermissionRead},
+			{Principal: bolted.AnyPrincipal, Matcher: dbpath.MustParseMatcher("shared/**"), Permissions: bolted.PermissionRead},
+		},
+	})
+
+	asPrincipal := func(principal string) context.Context {
+		return bolted.WithPrincipal(context.Background(), principal)
+	}
+
+	t.Run("operations", func(t *testing.T) {
+		cases := []struct {
+			name      string
+			principal string
+			fn        func(tx bolted.WriteTx)
+			denied    bool
+		}{
+			{
+				name:      "read own value",
+				principal: "alice",
+				fn:        func(tx bolted.WriteTx) { tx.Get(dbpath.ToPath("tenants", "alice", "name")) },
+			},
+			{
+				name:      "read other tenant's value",
+				principal: "alice",
+				fn:        func(tx bolted.WriteTx) { tx.Get(dbpath.ToPath("tenants", "bob", "name")) },
+				denied:    true,
+			},
+			{
+				name:      "read shared value",
+				principal: "",
+				fn:        func(tx bolted.WriteTx) { tx.Get(dbpath.ToPath("shared", "motd")) },
+			},
+			{
+				name:      "write shared value",
+				principal: "alice",
+				fn:        func(tx bolted.WriteTx) { tx.Put(dbpath.ToPath("shared", "motd"), []byte("x")) },
+				denied:    true,
+			},
+			{
+				name:      "write own value",
+				principal: "bob",
+				fn:        func(tx bolted.WriteTx) { tx.Put(dbpath.ToPath("tenants", "bob", "age"), []byte("1")) },
+			},
+			{
+				name:      "write without write permission",
+				principal: "carol",
+				fn:        func(tx bolted.WriteTx) { tx.Put(dbpath.ToPath("tenants", "carol", "age"), []byte("1")) },
+				denied:    true,
+			},
+			{
+				name:      "delete without delete permission",
+				principal: "bob",
+				fn:        func(tx bolted.WriteTx) { tx.Delete(dbpath.ToPath("tenants", "bob", "name")) },
+				denied:    true,
+			},
+			{
+				name:      "delete own value",
+				principal: "alice",
+				fn: func(tx bolted.WriteTx) {
+					tx.Put(dbpath.ToPath("tenants", "alice", "tmp"), []byte("x"))
+					tx.Delete(dbpath.ToPath("tenants", "alice", "tmp"))
+				},
+			},
+			{
+				name:      "put many with one denied key",
+				principal: "alice",
+				fn: func(tx bolted.WriteTx) {
+					tx.PutMany(dbpath.ToPath("tenants"), []bolted.KeyValue{{Key: "x", Value: []byte("x")}})
+				},
+				denied: true,
+			},
+			{
+				name:      "get many of other tenant",
+				principal: "alice",
+				fn:        func(tx bolted.WriteTx) { tx.GetMany(dbpath.ToPath("tenants", "bob"), []string{"name"}) },
+				denied:    true,
+			},
+			{
+				name:      "create map outside of own subtree",
+				principal: "alice",
+				fn:        func(tx bolted.WriteTx) { tx.CreateMap(dbpath.ToPath("alice")) },
+				denied:    true,
+			},
+			{
+				name:      "iterate invisible map",
+				principal: "alice",
+				fn:        func(tx bolted.WriteTx) { tx.Iterate(dbpath.ToPath("tenants", "bob")) },
+				denied:    true,
+			},
+			{
+				name:      "size of map with hidden children",
+				principal: "alice",
+				fn:        func(tx bolted.WriteTx) { tx.GetSizeOf(dbpath.ToPath("tenants")) },
+				denied:    true,
+			},
+			{
+				name:      "dump database",
+				principal: "alice",
+				fn:        func(tx bolted.WriteTx) { tx.DumpDatabase(nil) },
+				denied:    true,
+			},
+		}
+
+		for _, c := range cases {
+			t.Run(c.name, func(t *testing.T) {
+				err := adb.WriteWithContext(asPrincipal(c.principal), func(tx bolted.WriteTx) error {
+					c.fn(tx)
+					return nil
+				})
+				if c.denied {
+					require.True(t, bolted.IsPermissionDenied(err), "expected permission denied, got %v", err)
+					return
+				}
+				require.NoError(t, err)
+			})
+		}
+	})
+
+	t.Run("unreadable children are hidden", func(t *testing.T) {
+		err := adb.ReadWithContext(asPrincipal("alice"), func(tx bolted.ReadTx) error {
+			require.Equal(t, []string{"shared", "tenants"}, collectKeys(tx.Iterate(dbpath.NilPath)))
+			require.Equal(t, []string{"alice"}, collectKeys(tx.Iterate(dbpath.ToPath("tenants"))))
+			require.Equal(t, []string{"alice"}, collectKeys(tx.IterateRangeReverse(dbpath.ToPath("tenants"), bolted.Unbounded(), bolted.Unbounded())))
+
+			it := tx.Iterate(dbpath.ToPath("tenants"))
+			it.Seek("b")
+			require.True(t, it.IsDone())
+			it.Last()
+			require.Equal(t, "alice", it.GetKey())
+
+			require.Equal(t, []dbpath.Path{dbpath.ToPath("tenants", "alice", "name")}, tx.Find(dbpath.MustParseMatcher("tenants/*/name")))
+			require.True(t, tx.Exists(dbpath.ToPath("tenants")))
+
+			walked := []dbpath.Path{}
+			err := tx.Walk(dbpath.ToPath("tenants"), func(path dbpath.Path, _ bool, _ []byte) error {
+				walked = append(walked, path)
+				return nil
+			})
+			require.NoError(t, err)
+			require.Equal(t, []dbpath.Path{
+				dbpath.ToPath("tenants"),
+				dbpath.ToPath("tenants", "alice"),
+				dbpath.ToPath("tenants", "alice", "name"),
+			}, walked)
+			return nil
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("pages are filled with visible entries", func(t *testing.T) {
+		err := adb.ReadWithContext(asPrincipal("carol"), func(tx bolted.ReadTx) error {
+			res := tx.Page(dbpath.ToPath("tenants"), "", 1)
+			require.Equal(t, []bolted.PageEntry{{Key: "carol", IsMap: true}}, res.Entries)
+			require.Empty(t, res.NextToken)
+			return nil
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("anonymous principal", func(t *testing.T) {
+		err := adb.Read(func(tx bolted.ReadTx) error {
+			require.Equal(t, []string{"shared"}, collectKeys(tx.Iterate(dbpath.NilPath)))
+			return nil
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("principal from custom context value", func(t *testing.T) {
+		type userKey struct{}
+		cdb := bolted.WithACL(db, bolted.ACL{
+			Rules: []bolted.ACLRule{
+				{Principal: "admin", Matcher: dbpath.MustParseMatcher("**"), Permissions: bolted.PermissionAll},
+			},
+			Principal: func(ctx context.Context) string {
+				user, _ := ctx.Value(userKey{}).(string)
+				return user
+			},
+		})
+
+		err := cdb.ReadWithContext(context.WithValue(context.Background(), userKey{}, "admin"), func(tx bolted.ReadTx) error {
+			require.Equal(t, []string{"alice", "bob", "carol"}, collectKeys(tx.Iterate(dbpath.ToPath("tenants"))))
+			return nil
+		})
+		require.NoError(t, err)
+
+		err = cdb.Read(func(tx bolted.ReadTx) error {
+			tx.Get(dbpath.ToPath("shared", "motd"))
+			return nil
+		})
+		require.True(t, bolted.IsPermissionDenied(err))
+	})
+
+	t.Run("only visible changes are observed", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(asPrincipal("alice"))
+		defer cancel()
+
+		updates := adb.Observe(ctx, dbpath.MustParseMatcher("tenants/**"))
+		require.Equal(t, bolted.ObservedChanges{}, <-updates)
+
+		err := db.Write(func(tx bolted.WriteTx) error {
+			tx.Put(dbpath.ToPath("tenants", "bob", "name"), []byte("robert"))
+			tx.Put(dbpath.ToPath("tenants", "alice", "name"), []byte("alicia"))
+			return nil
+		})
+		require.NoError(t, err)
+
+		require.Equal(t, bolted.ObservedChanges{
+			{Path: dbpath.ToPath("tenants", "alice", "name"), Type: bolted.ChangeTypeValueSet},
+		}, <-updates)
+	})
+}
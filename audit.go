@@ -0,0 +1,413 @@
+package bolted
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/draganm/bolted/dbpath"
+)
+
+// Audit records are stored in a bucket next to the root bucket, keyed by their big endian sequence number.
+// The bucket can't be reached through transactions, so records can only be appended.
+const auditBucketName = "bolted:audit"
+
+// The clock can go backwards between transactions, so queries by time use an index keyed by
+// the big endian commit time in nanoseconds followed by the key of the record.
+const auditTimeIndexBucketName = "bolted:audit-time"
+
+const auditRecordVersion = 1
+
+var errInvalidAuditRecord = errors.New("invalid audit record")
+
+// AuditRecord records a committed write transaction.
+type AuditRecord struct {
+	TxID      uint64
+	Time      time.Time
+	Principal string
+	Reason    string
+	Changes   ObservedChanges
+}
+
+type reasonContextKey struct{}
+
+// WithReason returns a context giving the reason of write transactions recorded in the audit log.
+func WithReason(ctx context.Context, reason string) context.Context {
+	return context.WithValue(ctx, reasonContextKey{}, reason)
+}
+
+// ReasonFromContext returns the reason set by WithReason, or an empty string if there is none.
+func ReasonFromContext(ctx context.Context) string {
+	reason, _ := ctx.Value(reasonContextKey{}).(string)
+	return reason
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	return append(b, buf[:n]...)
+}
+
+func appendAuditString(b []byte, s string) []byte {
+	b = appendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+func appendAuditPath(b []byte, p dbpath.Path) []byte {
+	b = appendUvarint(b, uint64(len(p)))
+	for _, e := range p {
+		b = appendAuditString(b, e)
+	}
+	return b
+}
+
+// encodeAuditRecord encodes the record followed by the digests of the values written by the transaction.
+func encodeAuditRecord(r AuditRecord, digests []valueDigest) []byte {
+	b := []byte{auditRecordVersion}
+	b = appendUvarint(b, r.TxID)
+	b = appendUvarint(b, uint64(r.Time.UnixNano()))
+	b = appendAuditString(b, r.Principal)
+	b = appendAuditString(b, r.Reason)
+	b = appendUvarint(b, uint64(len(r.Changes)))
+	for _, c := range r.Changes {
+		b = appendUvarint(b, uint64(c.Type))
+		b = appendAuditPath(b, c.Path)
+	}
+	b = appendUvarint(b, uint64(len(digests)))
+	for _, d := range digests {
+		b = appendAuditPath(b, d.path)
+		b = append(b, d.digest...)
+	}
+	return b
+}
+
+// auditDecoder reads fields of an encoded audit record, remembering the first error.
+type auditDecoder struct {
+	b   []byte
+	err error
+}
+
+func (d *auditDecoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.b)
+	if n <= 0 {
+		d.err = errInvalidAuditRecord
+		return 0
+	}
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *auditDecoder) string() string {
+	l := d.uvarint()
+	if d.err != nil {
+		return ""
+	}
+	if uint64(len(d.b)) < l {
+		d.err = errInvalidAuditRecord
+		return ""
+	}
+	s := string(d.b[:l])
+	d.b = d.b[l:]
+	return s
+}
+
+func (d *auditDecoder) path() dbpath.Path {
+	l := d.uvarint()
+	p := dbpath.Path{}
+	for i := uint64(0); i < l && d.err == nil; i++ {
+		p = append(p, d.string())
+	}
+	return p
+}
+
+func (d *auditDecoder) digest() []byte {
+	if d.err != nil {
+		return nil
+	}
+	if len(d.b) < sha256.Size {
+		d.err = errInvalidAuditRecord
+		return nil
+	}
+	digest := d.b[:sha256.Size]
+	d.b = d.b[sha256.Size:]
+	return digest
+}
+
+func decodeAuditRecord(v []byte) (AuditRecord, []valueDigest, error) {
+	if len(v) == 0 || v[0] != auditRecordVersion {
+		return AuditRecord{}, nil, fmt.Errorf("%w: unsupported version", errInvalidAuditRecord)
+	}
+
+	d := &auditDecoder{b: v[1:]}
+
+	r := AuditRecord{
+		TxID:      d.uvarint(),
+		Time:      time.Unix(0, int64(d.uvarint())).UTC(),
+		Principal: d.string(),
+		Reason:    d.string(),
+	}
+
+	numberOfChanges := d.uvarint()
+	r.Changes = ObservedChanges{}
+	for i := uint64(0); i < numberOfChanges && d.err == nil; i++ {
+		c := ObservedChange{Type: ChangeType(d.uvarint())}
+		c.Path = d.path()
+		r.Changes = append(r.Changes, c)
+	}
+
+	numberOfDigests := d.uvarint()
+	digests := []valueDigest{}
+	for i := uint64(0); i < numberOfDigests && d.err == nil; i++ {
+		digests = append(digests, valueDigest{path: d.path(), digest: d.digest()})
+	}
+
+	if d.err != nil {
+		return AuditRecord{}, nil, d.err
+	}
+
+	if len(d.b) != 0 {
+		return AuditRecord{}, nil, fmt.Errorf("%w: %d trailing bytes", errInvalidAuditRecord, len(d.b))
+	}
+
+	return r, digests, nil
+}
+
+// committedChanges returns the changes of the transaction, merged the same way observers receive them.
+func (w *writeTx) committedChanges() ObservedChanges {
+	changes := ObservedChanges{}
+	for _, c := range w.observer.changes {
+		changes = changes.Update(c.Path, c.Type)
+	}
+	return changes
+}
+
+// storedPath returns the path made of the stored keys of its elements,
+// so elements matched by EncryptedPathElements are recorded encrypted.
+func (w *writeTx) storedPath(path dbpath.Path) dbpath.Path {
+	stored := dbpath.Path{}
+	for i := range path {
+		stored = append(stored, string(w.storedKey(path[:i+1])))
+	}
+	return stored
+}
+
+// logicalPath returns the path of the stored path returned by storedPath.
+func (w *writeTx) logicalPath(stored dbpath.Path) dbpath.Path {
+	path := dbpath.Path{}
+	for _, e := range stored {
+		path = w.childPath(path, []byte(e))
+	}
+	return path
+}
+
+// recordAudit appends the record of the transaction to the audit log, unless the transaction didn't change anything.
+// Values rewritten without a change, e.g. by re-encryption, are recorded by their digests only.
+func (w *writeTx) recordAudit() error {
+	if !w.audit || (len(w.observer.changes) == 0 && len(w.rewritten) == 0) {
+		return nil
+	}
+
+	bucket := w.btx.Bucket([]byte(auditBucketName))
+	if bucket == nil {
+		var err error
+		bucket, err = w.btx.CreateBucket([]byte(auditBucketName))
+		if err != nil {
+			return err
+		}
+	}
+
+	seq, err := bucket.NextSequence()
+	if err != nil {
+		return err
+	}
+
+	record := AuditRecord{
+		TxID:      w.ID(),
+		Time:      time.Now().UTC(),
+		Principal: PrincipalFromContext(w.ctx),
+		Reason:    ReasonFromContext(w.ctx),
+		Changes:   ObservedChanges{},
+	}
+
+	written := []dbpath.Path{}
+
+	for _, c := range w.committedChanges() {
+		record.Changes = append(record.Changes, ObservedChange{Path: w.storedPath(c.Path), Type: c.Type})
+		if c.Type == ChangeTypeValueSet {
+			written = append(written, c.Path)
+		}
+	}
+
+	digests, err := w.valueDigests(append(written, w.rewritten...))
+	if err != nil {
+		return err
+	}
+
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, seq)
+
+	// records are only appended, so pages can be filled completely
+	bucket.SetFillPercent(1.0)
+
+	encoded := encodeAuditRecord(record, digests)
+
+	err = bucket.Put(key, encoded)
+	if err != nil {
+		return err
+	}
+
+	index := w.btx.Bucket([]byte(auditTimeIndexBucketName))
+	if index == nil {
+		index, err = w.btx.CreateBucket([]byte(auditTimeIndexBucketName))
+		if err != nil {
+			return err
+		}
+	}
+
+	err = index.Put(append(auditTimeKey(record.Time), key...), []byte{})
+	if err != nil {
+		return err
+	}
+
+	return w.chainCommit(record.TxID, key, encoded)
+}
+
+// AuditQuery selects audit records. Zero values of all fields don't limit the records.
+type AuditQuery struct {
+	// Prefix selects records changing the path or paths below it.
+	// Changes of other paths are removed from the returned records.
+	Prefix dbpath.Path
+	// From selects records of transactions committed at or after the time.
+	From time.Time
+	// To selects records of transactions committed before the time.
+	To time.Time
+}
+
+func (q AuditQuery) affects(c ObservedChange) bool {
+	if q.Prefix.IsPrefixOf(c.Path) {
+		return true
+	}
+	// deletion of a parent deletes the prefix as well
+	return c.Type == ChangeTypeDeleted && c.Path.IsPrefixOf(q.Prefix)
+}
+
+func (q AuditQuery) selects(r AuditRecord) (AuditRecord, bool) {
+	if !q.From.IsZero() && r.Time.Before(q.From) {
+		return r, false
+	}
+
+	if !q.To.IsZero() && !r.Time.Before(q.To) {
+		return r, false
+	}
+
+	changes := ObservedChanges{}
+	for _, c := range r.Changes {
+		if q.affects(c) {
+			changes = append(changes, c)
+		}
+	}
+
+	r.Changes = changes
+
+	return r, len(changes) > 0
+}
+
+// auditTimeKey returns the prefix of keys of the time index for records committed at the time.
+// Times before the epoch have the same prefix as the epoch.
+func auditTimeKey(t time.Time) []byte {
+	k := make([]byte, 8)
+	binary.BigEndian.PutUint64(k, uint64(max(t.UnixNano(), 0)))
+	return k
+}
+
+// auditKeysInTimeRange returns the keys of records selected by From and To of the query, in the order of their transactions.
+func (w *writeTx) auditKeysInTimeRange(index storageBucket, q AuditQuery) [][]byte {
+	keys := [][]byte{}
+
+	c := index.Cursor()
+	k, _ := c.First()
+	if !q.From.IsZero() {
+		k, _ = c.Seek(auditTimeKey(q.From))
+	}
+
+	for ; k != nil; k, _ = c.Next() {
+		w.checkForCancelledContext()
+		if !q.To.IsZero() && bytes.Compare(k[:8], auditTimeKey(q.To)) >= 0 {
+			break
+		}
+		keys = append(keys, k[8:])
+	}
+
+	slices.SortFunc(keys, bytes.Compare)
+
+	return keys
+}
+
+func (w *writeTx) queryAuditLog(q AuditQuery) ([]AuditRecord, error) {
+	records := []AuditRecord{}
+
+	bucket := w.btx.Bucket([]byte(auditBucketName))
+	if bucket == nil {
+		return records, nil
+	}
+
+	add := func(k, v []byte) error {
+		r, _, err := decodeAuditRecord(v)
+		if err != nil {
+			return fmt.Errorf("while decoding audit record %d: %w", binary.BigEndian.Uint64(k), err)
+		}
+
+		for i, c := range r.Changes {
+			r.Changes[i].Path = w.logicalPath(c.Path)
+		}
+
+		r, selected := q.selects(r)
+		if selected {
+			records = append(records, r)
+		}
+
+		return nil
+	}
+
+	index := w.btx.Bucket([]byte(auditTimeIndexBucketName))
+	if index != nil && (!q.From.IsZero() || !q.To.IsZero()) {
+		for _, k := range w.auditKeysInTimeRange(index, q) {
+			err := add(k, bucket.Get(k))
+			if err != nil {
+				return nil, err
+			}
+		}
+		return records, nil
+	}
+
+	c := bucket.Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		w.checkForCancelledContext()
+		err := add(k, v)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return records, nil
+}
+
+// AuditLog returns audit records selected by the query in the order of their transactions.
+func (b *LocalDB) AuditLog(ctx context.Context, q AuditQuery) (records []AuditRecord, err error) {
+	err = b.ReadWithContext(ctx, func(tx ReadTx) error {
+		records, err = tx.(*writeTx).queryAuditLog(q)
+		if err != nil {
+			return fmt.Errorf("while querying audit log: %w", err)
+		}
+		return nil
+	})
+	return records, err
+}
@@ -0,0 +1,284 @@
+package bolted_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/draganm/bolted"
+	"github.com/draganm/bolted/dbpath"
+	"github.com/stretchr/testify/require"
+	"go.etcd.io/bbolt"
+)
+
+func TestAuditLog(t *testing.T) {
+	db, cleanup := openEmptyDatabase(t, bolted.Options{Audit: true})
+	defer cleanup()
+
+	bdb := db.(*bolted.LocalDB)
+
+	start := time.Now()
+
+	ctx := bolted.WithReason(bolted.WithPrincipal(context.Background(), "alice"), "initial import")
+	err := bdb.WriteWithContext(ctx, func(tx bolted.WriteTx) error {
+		tx.CreateMap(dbpath.ToPath("a"))
+		tx.Put(dbpath.ToPath("a", "v"), []byte("1"))
+		tx.CreateMap(dbpath.ToPath("b"))
+		return nil
+	})
+	require.NoError(t, err)
+
+	between := time.Now()
+
+	var deletionTxID uint64
+	err = bdb.WriteWithContext(bolted.WithPrincipal(context.Background(), "bob"), func(tx bolted.WriteTx) error {
+		deletionTxID = tx.ID()
+		tx.Put(dbpath.ToPath("b", "v"), []byte("2"))
+		tx.Delete(dbpath.ToPath("a"))
+		return nil
+	})
+	require.NoError(t, err)
+
+	t.Run("transactions without changes are not recorded", func(t *testing.T) {
+		err := bdb.Write(func(tx bolted.WriteTx) error {
+			tx.Get(dbpath.ToPath("b", "v"))
+			return nil
+		})
+		require.NoError(t, err)
+
+		err = bdb.Write(func(tx bolted.WriteTx) error {
+			tx.Put(dbpath.ToPath("b", "w"), []byte("3"))
+			return errors.New("failed")
+		})
+		require.Error(t, err)
+
+		records, err := bdb.AuditLog(context.Background(), bolted.AuditQuery{})
+		require.NoError(t, err)
+		require.Len(t, records, 2)
+	})
+
+	t.Run("records", func(t *testing.T) {
+		records, err := bdb.AuditLog(context.Background(), bolted.AuditQuery{})
+		require.NoError(t, err)
+		require.Len(t, records, 2)
+
+		require.Equal(t, "alice", records[0].Principal)
+		require.Equal(t, "initial import", records[0].Reason)
+		require.Equal(t, bolted.ObservedChanges{
+			{Path: dbpath.ToPath("a"), Type: bolted.ChangeTypeMapCreated},
+			{Path: dbpath.ToPath("a", "v"), Type: bolted.ChangeTypeValueSet},
+			{Path: dbpath.ToPath("b"), Type: bolted.ChangeTypeMapCreated},
+		}, records[0].Changes)
+		require.False(t, records[0].Time.Before(start))
+
+		require.Equal(t, deletionTxID, records[1].TxID)
+		require.Equal(t, "bob", records[1].Principal)
+		require.Equal(t, "", records[1].Reason)
+		require.Equal(t, bolted.ObservedChanges{
+			{Path: dbpath.ToPath("b", "v"), Type: bolted.ChangeTypeValueSet},
+			{Path: dbpath.ToPath("a"), Type: bolted.ChangeTypeDeleted},
+		}, records[1].Changes)
+	})
+
+	t.Run("queries", func(t *testing.T) {
+		cases := []struct {
+			name       string
+			query      bolted.AuditQuery
+			principals []string
+			changes    int
+		}{
+			{
+				name:       "prefix",
+				query:      bolted.AuditQuery{Prefix: dbpath.ToPath("b")},
+				principals: []string{"alice", "bob"},
+				changes:    2,
+			},
+			{
+				name:       "prefix deleted by parent",
+				query:      bolted.AuditQuery{Prefix: dbpath.ToPath("a", "v")},
+				principals: []string{"alice", "bob"},
+				changes:    2,
+			},
+			{
+				name:       "unchanged prefix",
+				query:      bolted.AuditQuery{Prefix: dbpath.ToPath("c")},
+				principals: []string{},
+			},
+			{
+				name:       "from",
+				query:      bolted.AuditQuery{From: between},
+				principals: []string{"bob"},
+				changes:    2,
+			},
+			{
+				name:       "to",
+				query:      bolted.AuditQuery{To: between},
+				principals: []string{"alice"},
+				changes:    3,
+			},
+			{
+				name:       "prefix and time range",
+				query:      bolted.AuditQuery{Prefix: dbpath.ToPath("a"), From: start, To: between},
+				principals: []string{"alice"},
+				changes:    2,
+			},
+		}
+
+		for _, c := range cases {
+			t.Run(c.name, func(t *testing.T) {
+				records, err := bdb.AuditLog(context.Background(), c.query)
+				require.NoError(t, err)
+
+				principals := []string{}
+				changes := 0
+				for _, r := range records {
+					principals = append(principals, r.Principal)
+					changes += len(r.Changes)
+				}
+				require.Equal(t, c.principals, principals)
+				require.Equal(t, c.changes, changes)
+			})
+		}
+	})
+}
+
+func TestAuditLogQueriesByTimeDecodeOnlyRecordsInRange(t *testing.T) {
+	td, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(td)
+
+	dbFile := filepath.Join(td, "db")
+
+	bdb, err := bolted.Open(dbFile, 0660, bolted.Options{Audit: true})
+	require.NoError(t, err)
+
+	times := []time.Time{}
+	for i := 0; i < 3; i++ {
+		time.Sleep(time.Millisecond)
+		times = append(times, time.Now())
+		err = bdb.Write(func(tx bolted.WriteTx) error {
+			tx.Put(dbpath.ToPath("v"), []byte{byte(i)})
+			return nil
+		})
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, bdb.Close())
+
+	db, err := bbolt.Open(dbFile, 0660, nil)
+	require.NoError(t, err)
+	err = db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte("bolted:audit")).Put([]byte{0, 0, 0, 0, 0, 0, 0, 1}, []byte("corrupt"))
+	})
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	bdb, err = bolted.Open(dbFile, 0660, bolted.Options{Audit: true})
+	require.NoError(t, err)
+	defer bdb.Close()
+
+	_, err = bdb.AuditLog(context.Background(), bolted.AuditQuery{})
+	require.Error(t, err)
+
+	records, err := bdb.AuditLog(context.Background(), bolted.AuditQuery{From: times[1]})
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+
+	records, err = bdb.AuditLog(context.Background(), bolted.AuditQuery{From: times[1], To: times[2]})
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+
+	_, err = bdb.AuditLog(context.Background(), bolted.AuditQuery{To: times[1]})
+	require.Error(t, err)
+}
+
+func TestAuditLogIsOptIn(t *testing.T) {
+	db, cleanup := openEmptyDatabase(t, bolted.Options{})
+	defer cleanup()
+
+	bdb := db.(*bolted.LocalDB)
+
+	err := bdb.Write(func(tx bolted.WriteTx) error {
+		tx.Put(dbpath.ToPath("v"), []byte("1"))
+		return nil
+	})
+	require.NoError(t, err)
+
+	records, err := bdb.AuditLog(context.Background(), bolted.AuditQuery{})
+	require.NoError(t, err)
+	require.Empty(t, records)
+}
+
+func TestAuditLogOfEncryptedPathElements(t *testing.T) {
+	td, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(td)
+
+	dbFile := filepath.Join(td, "db")
+
+	secret := dbpath.ToPath("customers", "alice@example.com")
+
+	bdb, err := bolted.Open(dbFile, 0660, bolted.Options{
+		Audit:                 true,
+		EncryptedPathElements: dbpath.MustParseMatcher("customers/*"),
+		PathElementKey:        bytes.Repeat([]byte{1}, 32),
+	})
+	require.NoError(t, err)
+
+	err = bdb.Write(func(tx bolted.WriteTx) error {
+		tx.CreateMap(dbpath.ToPath("customers"))
+		tx.Put(secret, []byte("1"))
+		return nil
+	})
+	require.NoError(t, err)
+
+	records, err := bdb.AuditLog(context.Background(), bolted.AuditQuery{Prefix: secret})
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	require.Equal(t, bolted.ObservedChanges{
+		{Path: secret, Type: bolted.ChangeTypeValueSet},
+	}, records[0].Changes)
+
+	require.NoError(t, bdb.Close())
+
+	db, err := bbolt.Open(dbFile, 0660, nil)
+	require.NoError(t, err)
+	err = db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte("bolted:audit")).ForEach(func(k, v []byte) error {
+			require.False(t, bytes.Contains(v, []byte("alice@example.com")))
+			return nil
+		})
+	})
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+}
+
+func TestAuditLogOfACLPrincipal(t *testing.T) {
+	db, cleanup := openEmptyDatabase(t, bolted.Options{Audit: true})
+	defer cleanup()
+
+	adb := bolted.WithACL(db, bolted.ACL{
+		Rules: []bolted.ACLRule{
+			{Principal: "alice", Matcher: dbpath.MustParseMatcher("**"), Permissions: bolted.PermissionAll},
+		},
+		Principal: func(ctx context.Context) string {
+			return "alice"
+		},
+	})
+
+	err := adb.Write(func(tx bolted.WriteTx) error {
+		tx.Put(dbpath.ToPath("v"), []byte("1"))
+		return nil
+	})
+	require.NoError(t, err)
+
+	records, err := db.(*bolted.LocalDB).AuditLog(context.Background(), bolted.AuditQuery{})
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	require.Equal(t, "alice", records[0].Principal)
+}
@@ -0,0 +1,287 @@
+package bolted
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/draganm/bolted/dbpath"
+)
+
+// Blobs are values split into chunks stored in a hidden bucket.
+// The bucket is marked with blobMarkerKey holding the logical size of the blob,
+// chunks are stored under big endian encoded chunk indexes.
+const blobMarkerKey = "\x00bolted:blob"
+
+const blobChunkSize = 256 * 1024
+
+var errReservedKey = errors.New("key is reserved for blobs")
+
+var errCorruptBlob = errors.New("corrupt blob")
+
+// checkKey rejects putting values or maps under the blob marker key, which would turn the parent map into a blob.
+func checkKey(path dbpath.Path) error {
+	if len(path) > 0 && path[len(path)-1] == blobMarkerKey {
+		return errReservedKey
+	}
+	return nil
+}
+
+func isBlob(b storageBucket) bool {
+	return b != nil && b.Get([]byte(blobMarkerKey)) != nil
+}
+
+func blobSize(b storageBucket) uint64 {
+	return binary.BigEndian.Uint64(b.Get([]byte(blobMarkerKey)))
+}
+
+func blobChunkKey(i uint64) []byte {
+	k := make([]byte, 8)
+	binary.BigEndian.PutUint64(k, i)
+	return k
+}
+
+// blobChunk returns the i-th chunk of a blob of the given size.
+// All chunks but the last one are full, so a chunk of any other length means the blob is corrupt.
+func blobChunk(b storageBucket, i, size uint64) ([]byte, error) {
+	chunk := b.Get(blobChunkKey(i))
+	if uint64(len(chunk)) != min(blobChunkSize, size-i*blobChunkSize) {
+		return nil, fmt.Errorf("%w: chunk %d has %d bytes", errCorruptBlob, i, len(chunk))
+	}
+	return chunk, nil
+}
+
+// readBlob returns a copy of the whole content of the blob.
+func readBlob(b storageBucket) ([]byte, error) {
+	size := blobSize(b)
+	v := make([]byte, 0, size)
+	for i := uint64(0); uint64(len(v)) < size; i++ {
+		chunk, err := blobChunk(b, i, size)
+		if err != nil {
+			return nil, err
+		}
+		v = append(v, chunk...)
+	}
+	return v, nil
+}
+
+// deleteBlob deletes the blob stored under the key so it can be replaced with a value.
+// It returns true if there was a blob.
+func deleteBlob(bucket storageBucket, key []byte) (bool, error) {
+	if !isBlob(bucket.Bucket(key)) {
+		return false, nil
+	}
+	return true, bucket.DeleteBucket(key)
+}
+
+func (w *writeTx) PutReader(path dbpath.Path, r io.Reader) {
+	w.checkForCancelledContext()
+
+	if len(path) == 0 {
+		raiseErrorForPath(path, "PutReader", errors.New("value cannot be put as root"))
+	}
+
+	err := checkKey(path)
+	if err != nil {
+		raiseErrorForPath(path, "PutReader", err)
+	}
+
+	bucket, err := w.bucket(path[:len(path)-1])
+	if err != nil {
+		raiseErrorForPath(path, "PutReader", err)
+	}
+
+	last := w.storedKey(path)
+
+	limit, limited, err := w.quotaLimit(bucket, path, last)
+	if err != nil {
+		raiseErrorForPath(path, "PutReader", err)
+	}
+
+	if limited && limit < math.MaxInt64 {
+		// reading stops right after the limit is exceeded, the exceeded quota is reported when the value is charged
+		r = io.LimitReader(r, int64(limit)+1)
+	}
+
+	if w.encrypts(path) {
+		// blob chunks are not encrypted, so the whole value is read and stored encrypted
+		v, err := io.ReadAll(r)
+		if err != nil {
+			raiseErrorForPath(path, "PutReader", fmt.Errorf("while reading: %w", err))
+		}
+		err = w.put(path, v)
+		if err != nil {
+			raiseErrorForPath(path, "PutReader", err)
+		}
+		w.observer.put(path)
+		return
+	}
+
+	// the size is not known yet, so the old value is released first and the blob is charged once it is stored
+	err = w.chargeQuotaForPut(bucket, path, last, 0)
+	if err != nil {
+		raiseErrorForPath(path, "PutReader", err)
+	}
+
+	bucket.SetFillPercent(w.fillPercent)
+
+	exists := false
+
+	switch old := bucket.Get(last); {
+	case old != nil:
+		exists = true
+		err = w.releaseValue(old)
+		if err == nil {
+			err = bucket.Delete(last)
+		}
+	case bucket.Bucket(last) != nil:
+		exists, err = deleteBlob(bucket, last)
+		if err == nil && !exists {
+			err = ErrConflict
+		}
+	}
+
+	if err != nil {
+		raiseErrorForPath(path, "PutReader", err)
+	}
+
+	blob, err := bucket.CreateBucket(last)
+	if err != nil {
+		raiseErrorForPath(path, "PutReader", err)
+	}
+
+	blob.SetFillPercent(w.fillPercent)
+
+	size := uint64(0)
+
+	for i := uint64(0); ; i++ {
+		w.checkForCancelledContext()
+		// bbolt keeps a reference to put values until the end of the transaction,
+		// so every chunk needs its own buffer
+		chunk := make([]byte, blobChunkSize)
+		n, err := io.ReadFull(r, chunk)
+		if n > 0 {
+			perr := blob.Put(blobChunkKey(i), chunk[:n])
+			if perr != nil {
+				raiseErrorForPath(path, "PutReader", perr)
+			}
+			size += uint64(n)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			raiseErrorForPath(path, "PutReader", fmt.Errorf("while reading: %w", err))
+		}
+	}
+
+	sizeBytes := make([]byte, 8)
+	binary.BigEndian.PutUint64(sizeBytes, size)
+
+	err = blob.Put([]byte(blobMarkerKey), sizeBytes)
+	if err != nil {
+		raiseErrorForPath(path, "PutReader", err)
+	}
+
+	if w.tracksQuotas() {
+		err = w.chargeQuota(path, quotaDelta{bytes: int64(size), valueSize: size})
+		if err != nil {
+			raiseErrorForPath(path, "PutReader", err)
+		}
+	}
+
+	if !exists {
+		bucket.NextSequence()
+	}
+
+	w.observer.put(path)
+}
+
+// OpenReader returns a reader of the value or blob stored under the path.
+// The reader is only valid until the end of the transaction.
+func (w *writeTx) OpenReader(path dbpath.Path) io.ReadSeeker {
+	w.checkForCancelledContext()
+
+	if len(path) == 0 {
+		raiseErrorForPath(path, "OpenReader", errors.New("cannot get value of root"))
+	}
+
+	bucket, err := w.bucket(path[:len(path)-1])
+	if err != nil {
+		raiseErrorForPath(path, "OpenReader", err)
+	}
+
+	last := w.storedKey(path)
+
+	v := bucket.Get(last)
+	if v != nil {
+		v, err = w.decodeValue(path, v)
+		if err != nil {
+			raiseErrorForPath(path, "OpenReader", err)
+		}
+		return bytes.NewReader(v)
+	}
+
+	blob := bucket.Bucket(last)
+	if !isBlob(blob) {
+		raiseErrorForPath(path, "OpenReader", errors.New("value not found"))
+	}
+
+	return &blobReader{
+		b:    blob,
+		size: int64(blobSize(blob)),
+		w:    w,
+	}
+}
+
+type blobReader struct {
+	b      storageBucket
+	size   int64
+	offset int64
+	w      *writeTx
+}
+
+func (br *blobReader) Read(p []byte) (int, error) {
+	br.w.checkForCancelledContext()
+
+	if br.offset >= br.size {
+		return 0, io.EOF
+	}
+
+	read := 0
+	for read < len(p) && br.offset < br.size {
+		chunk, err := blobChunk(br.b, uint64(br.offset/blobChunkSize), uint64(br.size))
+		if err != nil {
+			return read, err
+		}
+		n := copy(p[read:], chunk[br.offset%blobChunkSize:])
+		read += n
+		br.offset += int64(n)
+	}
+
+	return read, nil
+}
+
+func (br *blobReader) Seek(offset int64, whence int) (int64, error) {
+	var abs int64
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = br.offset + offset
+	case io.SeekEnd:
+		abs = br.size + offset
+	default:
+		return 0, errors.New("invalid whence")
+	}
+
+	if abs < 0 {
+		return 0, errors.New("negative position")
+	}
+
+	br.offset = abs
+	return abs, nil
+}
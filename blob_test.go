@@ -0,0 +1,240 @@
+package bolted_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/draganm/bolted"
+	"github.com/draganm/bolted/dbpath"
+	"github.com/stretchr/testify/require"
+	"go.etcd.io/bbolt"
+)
+
+func randomBytes(size int) []byte {
+	b := make([]byte, size)
+	rand.New(rand.NewSource(42)).Read(b)
+	return b
+}
+
+func TestBlobs(t *testing.T) {
+	bdb, cleanup := openEmptyDatabase(t, bolted.Options{})
+	defer cleanup()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	updates := bdb.Observe(ctx, dbpath.Matcher{}.AppendAnySubpathMatcher())
+	<-updates
+
+	content := randomBytes(1024*1024 + 17)
+	blobPath := dbpath.ToPath("blob")
+
+	t.Run("put reader", func(t *testing.T) {
+		err := bdb.Write(func(tx bolted.WriteTx) error {
+			tx.PutReader(blobPath, bytes.NewReader(content))
+			return nil
+		})
+		require.NoError(t, err)
+
+		require.Equal(t, bolted.ObservedChanges{
+			{Path: blobPath, Type: bolted.ChangeTypeValueSet},
+		}, <-updates)
+	})
+
+	t.Run("blob behaves like a value", func(t *testing.T) {
+		err := bdb.Read(func(tx bolted.ReadTx) error {
+			require.True(t, tx.Exists(blobPath))
+			require.False(t, tx.IsMap(blobPath))
+			require.Equal(t, uint64(len(content)), tx.GetSizeOf(blobPath))
+			require.Equal(t, uint64(1), tx.GetSizeOf(dbpath.NilPath))
+			require.True(t, bytes.Equal(content, tx.Get(blobPath)))
+
+			it := tx.Iterate(dbpath.NilPath)
+			require.Equal(t, "blob", it.GetKey())
+			require.False(t, it.IsMap())
+			require.Equal(t, uint64(len(content)), it.GetSize())
+			require.True(t, bytes.Equal(content, it.GetValue()))
+
+			require.Equal(t, []dbpath.Path{blobPath}, tx.Find(dbpath.MustParseMatcher("*")))
+			return nil
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("blob can't be used as a map", func(t *testing.T) {
+		err := bdb.Write(func(tx bolted.WriteTx) error {
+			require.False(t, tx.Exists(blobPath.Append("\x00\x00\x00\x00\x00\x00\x00\x00")))
+			tx.Put(blobPath.Append("x"), []byte{1})
+			return nil
+		})
+		require.Error(t, err)
+	})
+
+	t.Run("open reader", func(t *testing.T) {
+		err := bdb.Read(func(tx bolted.ReadTx) error {
+			r := tx.OpenReader(blobPath)
+
+			read, err := io.ReadAll(r)
+			require.NoError(t, err)
+			require.True(t, bytes.Equal(content, read))
+
+			_, err = r.Seek(300*1024, io.SeekStart)
+			require.NoError(t, err)
+
+			part := make([]byte, 10)
+			_, err = io.ReadFull(r, part)
+			require.NoError(t, err)
+			require.Equal(t, content[300*1024:300*1024+10], part)
+
+			pos, err := r.Seek(-5, io.SeekEnd)
+			require.NoError(t, err)
+			require.Equal(t, int64(len(content)-5), pos)
+
+			read, err = io.ReadAll(r)
+			require.NoError(t, err)
+			require.Equal(t, content[len(content)-5:], read)
+			return nil
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("open reader of a plain value", func(t *testing.T) {
+		err := bdb.Write(func(tx bolted.WriteTx) error {
+			tx.Put(dbpath.ToPath("plain"), []byte("abc"))
+			read, err := io.ReadAll(tx.OpenReader(dbpath.ToPath("plain")))
+			require.NoError(t, err)
+			require.Equal(t, []byte("abc"), read)
+			return nil
+		})
+		require.NoError(t, err)
+		<-updates
+	})
+
+	t.Run("replace blob with value and value with blob", func(t *testing.T) {
+		err := bdb.Write(func(tx bolted.WriteTx) error {
+			tx.Put(blobPath, []byte{1})
+			require.Equal(t, []byte{1}, tx.Get(blobPath))
+
+			tx.PutReader(dbpath.ToPath("plain"), bytes.NewReader(nil))
+			require.Equal(t, []byte{}, tx.Get(dbpath.ToPath("plain")))
+			require.Equal(t, uint64(0), tx.GetSizeOf(dbpath.ToPath("plain")))
+
+			require.Equal(t, uint64(2), tx.GetSizeOf(dbpath.NilPath))
+			return nil
+		})
+		require.NoError(t, err)
+		<-updates
+	})
+
+	t.Run("delete blob", func(t *testing.T) {
+		err := bdb.Write(func(tx bolted.WriteTx) error {
+			tx.Delete(dbpath.ToPath("plain"))
+			require.False(t, tx.Exists(dbpath.ToPath("plain")))
+			require.Equal(t, uint64(1), tx.GetSizeOf(dbpath.NilPath))
+			return nil
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("put reader over map", func(t *testing.T) {
+		err := bdb.Write(func(tx bolted.WriteTx) error {
+			tx.CreateMap(dbpath.ToPath("map"))
+			tx.PutReader(dbpath.ToPath("map"), bytes.NewReader(content))
+			return nil
+		})
+		require.True(t, bolted.IsConflict(err))
+	})
+
+	t.Run("blob marker key is reserved", func(t *testing.T) {
+		marker := "\x00bolted:blob"
+		cases := []struct {
+			name string
+			fn   func(tx bolted.WriteTx)
+		}{
+			{"put", func(tx bolted.WriteTx) { tx.Put(dbpath.ToPath("m", marker), []byte{0, 0, 0, 0, 0, 0, 0, 1}) }},
+			{"put many", func(tx bolted.WriteTx) {
+				tx.PutMany(dbpath.ToPath("m"), []bolted.KeyValue{{Key: marker, Value: []byte{1}}})
+			}},
+			{"put reader", func(tx bolted.WriteTx) { tx.PutReader(dbpath.ToPath("m", marker), bytes.NewReader(content)) }},
+			{"create map", func(tx bolted.WriteTx) { tx.CreateMap(dbpath.ToPath("m", marker)) }},
+		}
+
+		for _, c := range cases {
+			t.Run(c.name, func(t *testing.T) {
+				err := bdb.Write(func(tx bolted.WriteTx) error {
+					tx.CreateMap(dbpath.ToPath("m"))
+					c.fn(tx)
+					return nil
+				})
+				require.Error(t, err)
+				require.Contains(t, err.Error(), "reserved")
+			})
+		}
+	})
+}
+
+func TestCorruptBlobs(t *testing.T) {
+	td, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(td)
+
+	dbFile := filepath.Join(td, "db")
+
+	content := randomBytes(3*256*1024 + 17)
+	blobPath := dbpath.ToPath("blob")
+
+	cases := []struct {
+		name    string
+		corrupt func(blob *bbolt.Bucket) error
+	}{
+		{"missing chunk", func(blob *bbolt.Bucket) error {
+			return blob.Delete([]byte{0, 0, 0, 0, 0, 0, 0, 1})
+		}},
+		{"short chunk", func(blob *bbolt.Bucket) error {
+			return blob.Put([]byte{0, 0, 0, 0, 0, 0, 0, 1}, []byte{1, 2, 3})
+		}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			bdb, err := bolted.Open(dbFile, 0660, bolted.Options{})
+			require.NoError(t, err)
+			err = bdb.Write(func(tx bolted.WriteTx) error {
+				tx.PutReader(blobPath, bytes.NewReader(content))
+				return nil
+			})
+			require.NoError(t, err)
+			require.NoError(t, bdb.Close())
+
+			db, err := bbolt.Open(dbFile, 0660, nil)
+			require.NoError(t, err)
+			err = db.Update(func(tx *bbolt.Tx) error {
+				return c.corrupt(tx.Bucket([]byte("root")).Bucket([]byte("blob")))
+			})
+			require.NoError(t, err)
+			require.NoError(t, db.Close())
+
+			bdb, err = bolted.Open(dbFile, 0660, bolted.Options{})
+			require.NoError(t, err)
+			defer bdb.Close()
+
+			err = bdb.Read(func(tx bolted.ReadTx) error {
+				tx.Get(blobPath)
+				return nil
+			})
+			require.Error(t, err)
+
+			err = bdb.Read(func(tx bolted.ReadTx) error {
+				_, err := io.ReadAll(tx.OpenReader(blobPath))
+				return err
+			})
+			require.Error(t, err)
+		})
+	}
+}
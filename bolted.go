@@ -12,13 +12,50 @@ import (
 )
 
 type LocalDB struct {
-	path string
-	db   *bbolt.DB
-	obs  *observer
+	txOptions
+	path    string
+	db      *bbolt.DB
+	obs     *observer
+	options Options
 }
 
 type Options struct {
 	bbolt.Options
+	// PageTokenKey is used to encrypt and authenticate page tokens.
+	// If not set, a random key is generated and tokens are valid only until the database is closed,
+	// so it must be set for tokens to be accepted after a restart or by other instances sharing the database.
+	PageTokenKey []byte
+	// ContentAddressed matches paths whose values are stored only once per distinct content,
+	// deduplicated by their SHA-256. Unreferenced content is removed by CollectGarbage.
+	// Values written with PutReader are stored in chunks that are not deduplicated.
+	// Values of paths also matched by Encrypted are hashed after encryption with a random nonce,
+	// so they are stored per write and never deduplicated.
+	ContentAddressed dbpath.Matcher
+	// Compressed matches paths whose values are compressed on Put.
+	// Values stored before compression was enabled remain readable.
+	// Values written with PutReader are stored in chunks that are not compressed.
+	Compressed dbpath.Matcher
+	// Encrypted matches paths whose values are encrypted on Put with the current key of KeyProvider.
+	// Values stored before encryption was enabled remain readable and can be encrypted with ReencryptValues.
+	// PutReader reads values of encrypted paths into memory as a whole, so they are not streamed.
+	Encrypted dbpath.Matcher
+	// KeyProvider provides keys for encryption and decryption of values.
+	KeyProvider KeyProvider
+	// EncryptedPathElements matches paths whose last element is stored encrypted with PathElementKey.
+	// Exact lookups keep working, but the order of the stored elements is not preserved,
+	// so range and prefix iteration over maps with encrypted elements is not supported.
+	// Paths stored before encryption was enabled can't be looked up by exact path anymore.
+	EncryptedPathElements dbpath.Matcher
+	// PathElementKey is the key used to encrypt path elements. It must have at least 16 bytes.
+	PathElementKey []byte
+	// Quotas limit subtrees of matching maps. Writes exceeding a quota fail with ErrQuotaExceeded.
+	// Usage of a subtree is computed on its first change and tracked incrementally afterwards.
+	Quotas []Quota
+	// Audit records the principal and reason taken from the context, the ID, the time and the changes
+	// of every write transaction changing the database in the audit log, queried by AuditLog.
+	// Paths are recorded by their stored keys, so elements matched by EncryptedPathElements stay encrypted.
+	// Records are chained by their hashes, see CommitHash and VerifyCommitChain.
+	Audit bool
 }
 
 const rootBucketName = "root"
@@ -26,6 +63,11 @@ const rootBucketName = "root"
 var tracer = otel.Tracer("github.com/draganm/bolted")
 
 func Open(path string, mode os.FileMode, options Options) (*LocalDB, error) {
+	txOptions, err := newTxOptions(options)
+	if err != nil {
+		return nil, err
+	}
+
 	db, err := bbolt.Open(path, mode, &options.Options)
 	if err != nil {
 		return nil, fmt.Errorf("while opening bolt db: %w", err)
@@ -48,7 +90,8 @@ func Open(path string, mode os.FileMode, options Options) (*LocalDB, error) {
 			return nil, fmt.Errorf("while rolling back read transaction: %w", err)
 		}
 
-		if !rootExists {
+		// read-only files can't be modified, operations will fail if the root bucket is missing
+		if !rootExists && !options.ReadOnly {
 			err = db.Update(func(tx *bbolt.Tx) error {
 				b := tx.Bucket([]byte(rootBucketName))
 				if b == nil {
@@ -70,9 +113,11 @@ func Open(path string, mode os.FileMode, options Options) (*LocalDB, error) {
 	obs := newObserver()
 
 	b := &LocalDB{
-		path: path,
-		db:   db,
-		obs:  obs,
+		txOptions: txOptions,
+		path:      path,
+		db:        db,
+		obs:       obs,
+		options:   options,
 	}
 
 	initializeMetricsForDB(path, fileSize)
@@ -101,6 +146,10 @@ func (b *LocalDB) Write(fn func(tx WriteTx) error) (err error) {
 
 func (b *LocalDB) WriteWithContext(ctx context.Context, fn func(tx WriteTx) error) (err error) {
 
+	if b.options.ReadOnly {
+		return ErrReadOnly
+	}
+
 	ctx, span := tracer.Start(ctx, "Write")
 
 	defer func() {
@@ -159,17 +208,23 @@ func (b *LocalDB) WriteWithContext(ctx context.Context, fn func(tx WriteTx) erro
 
 		}()
 
-		rootBucket := btx.Bucket([]byte(rootBucketName))
+		stx := boltTx{btx}
 		wtx := &writeTx{
-			btx:         btx,
+			txOptions:   b.txOptions,
+			btx:         stx,
 			readOnly:    false,
-			rootBucket:  rootBucket,
+			rootBucket:  stx.Bucket([]byte(rootBucketName)),
 			fillPercent: bbolt.DefaultFillPercent,
 			observer:    txObserver,
 			ctx:         ctx,
 		}
 
-		return fn(wtx)
+		err = fn(wtx)
+		if err != nil {
+			return err
+		}
+
+		return wtx.beforeCommit()
 	})
 }
 
@@ -204,11 +259,12 @@ func (b *LocalDB) ReadWithContext(ctx context.Context, fn func(tx ReadTx) error)
 
 		}()
 
-		rootBucket := btx.Bucket([]byte(rootBucketName))
+		stx := boltTx{btx}
 		tx := &writeTx{
-			btx:         btx,
+			txOptions:   b.txOptions,
+			btx:         stx,
 			readOnly:    true,
-			rootBucket:  rootBucket,
+			rootBucket:  stx.Bucket([]byte(rootBucketName)),
 			fillPercent: bbolt.DefaultFillPercent,
 			ctx:         ctx,
 		}
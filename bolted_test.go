@@ -11,7 +11,7 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-func openEmptyDatabase(t *testing.T, opts bolted.Options) (bolted.Database, func()) {
+func openEmptyDatabase(t testing.TB, opts bolted.Options) (bolted.Database, func()) {
 	td, err := ioutil.TempDir("", "")
 	require.NoError(t, err)
 	removeTempDir := func() {
@@ -390,6 +390,35 @@ func TestIterator(t *testing.T) {
 
 	})
 
+	t.Run("iterating binary keys", func(t *testing.T) {
+		bdb, cleanup := openEmptyDatabase(t, bolted.Options{})
+		defer cleanup()
+
+		key := []byte{0x00, 0xff, '/', 0xc3, 0x28}
+
+		err := bdb.Write(func(tx bolted.WriteTx) error {
+			tx.Put(dbpath.FromBytes(key), []byte{1, 2, 3})
+			return nil
+		})
+		require.NoError(t, err)
+
+		err = bdb.Read(func(tx bolted.ReadTx) error {
+			it := tx.Iterate(dbpath.NilPath)
+
+			require.False(t, it.IsDone())
+			require.Equal(t, key, it.GetKeyBytes())
+			require.Equal(t, []byte{1, 2, 3}, tx.Get(dbpath.NilPath.AppendBytes(it.GetKeyBytes())))
+
+			it.Next()
+
+			require.True(t, it.IsDone())
+			require.Nil(t, it.GetKeyBytes())
+
+			return nil
+		})
+		require.NoError(t, err)
+
+	})
 }
 
 func TestSize(t *testing.T) {
@@ -0,0 +1,87 @@
+package bolted
+
+import (
+	"encoding/binary"
+	"errors"
+	"strings"
+
+	"github.com/draganm/bolted/dbpath"
+)
+
+var errRootBucketNotFound = errors.New("root bucket not found")
+var errParentBucketNotFound = errors.New("one of the parent buckets does not exist")
+
+// bucketCache keeps bucket handles resolved during a transaction,
+// keyed by the length-prefixed encoding of the map's path.
+type bucketCache map[string]storageBucket
+
+// appendCacheKey appends the element to the encoded path.
+// Length prefixes make the encoding of a path a prefix of the encodings of all its descendants only.
+func appendCacheKey(key []byte, element string) []byte {
+	var l [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(l[:], uint64(len(element)))
+	key = append(key, l[:n]...)
+	return append(key, element...)
+}
+
+func cacheKey(path dbpath.Path) string {
+	key := []byte{}
+	for _, e := range path {
+		key = appendCacheKey(key, e)
+	}
+	return string(key)
+}
+
+// invalidate removes the handles of the map at path and all of its descendants.
+func (bc bucketCache) invalidate(path dbpath.Path) {
+	prefix := cacheKey(path)
+	for k := range bc {
+		if strings.HasPrefix(k, prefix) {
+			delete(bc, k)
+		}
+	}
+}
+
+// bucket returns the bucket of the map at path.
+// Handles of the map and all of its ancestors are cached for the rest of the transaction.
+func (w *writeTx) bucket(path dbpath.Path) (storageBucket, error) {
+	if w.rootBucket == nil {
+		return nil, errRootBucketNotFound
+	}
+
+	if len(path) == 0 {
+		return w.rootBucket, nil
+	}
+
+	if w.buckets == nil {
+		w.buckets = bucketCache{}
+	}
+
+	keys := make([]string, len(path))
+	key := []byte{}
+	for i, e := range path {
+		key = appendCacheKey(key, e)
+		keys[i] = string(key)
+	}
+
+	bucket := w.rootBucket
+	resolved := 0
+	for i := len(path) - 1; i >= 0; i-- {
+		b, found := w.buckets[keys[i]]
+		if found {
+			bucket = b
+			resolved = i + 1
+			break
+		}
+	}
+
+	for i := resolved; i < len(path); i++ {
+		bucket = bucket.Bucket(w.storedKey(path[:i+1]))
+		if bucket == nil || isBlob(bucket) {
+			return nil, errParentBucketNotFound
+		}
+		w.buckets[keys[i]] = bucket
+	}
+
+	return bucket, nil
+}
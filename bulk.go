@@ -0,0 +1,90 @@
+package bolted
+
+import (
+	"errors"
+
+	"github.com/draganm/bolted/dbpath"
+)
+
+type KeyValue struct {
+	Key   string
+	Value []byte
+}
+
+// PutMany puts all values into the map at parent, resolving the map only once.
+func (w *writeTx) PutMany(parent dbpath.Path, kvs []KeyValue) {
+	w.checkForCancelledContext()
+
+	_, err := w.bucket(parent)
+	if err != nil {
+		raiseErrorForPath(parent, "PutMany", err)
+	}
+
+	for _, kv := range kvs {
+		path := parent.Append(kv.Key)
+
+		err = w.put(path, kv.Value)
+		if err != nil {
+			raiseErrorForPath(path, "PutMany", err)
+		}
+
+		w.observer.put(path)
+	}
+}
+
+// GetMany returns copies of the values stored under keys of the map at parent, resolving the map only once.
+func (w *writeTx) GetMany(parent dbpath.Path, keys []string) [][]byte {
+	w.checkForCancelledContext()
+
+	bucket, err := w.bucket(parent)
+	if err != nil {
+		raiseErrorForPath(parent, "GetMany", err)
+	}
+
+	values := make([][]byte, len(keys))
+
+	for i, k := range keys {
+		key := w.storedKey(parent.Append(k))
+		v := bucket.Get(key)
+		if v == nil {
+			blob := bucket.Bucket(key)
+			if isBlob(blob) {
+				values[i], err = readBlob(blob)
+				if err != nil {
+					raiseErrorForPath(parent.Append(k), "GetMany", err)
+				}
+				continue
+			}
+			raiseErrorForPath(parent.Append(k), "GetMany", errors.New("value not found"))
+		}
+		v, err = w.decodeValue(parent.Append(k), v)
+		if err != nil {
+			raiseErrorForPath(parent.Append(k), "GetMany", err)
+		}
+		values[i] = make([]byte, len(v))
+		copy(values[i], v)
+	}
+
+	return values
+}
+
+// DeleteMany deletes values and maps stored under keys of the map at parent, resolving the map only once.
+func (w *writeTx) DeleteMany(parent dbpath.Path, keys []string) {
+	w.checkForCancelledContext()
+
+	_, err := w.bucket(parent)
+	if err != nil {
+		raiseErrorForPath(parent, "DeleteMany", err)
+	}
+
+	for _, k := range keys {
+		path := parent.Append(k)
+
+		err = w.delete(path)
+		if err != nil {
+			raiseErrorForPath(path, "DeleteMany", err)
+		}
+
+		w.observer.delete(path)
+	}
+}
@@ -0,0 +1,183 @@
+package bolted_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/draganm/bolted"
+	"github.com/draganm/bolted/dbpath"
+	"github.com/stretchr/testify/require"
+)
+
+var deepPath = dbpath.ToPath("a", "b", "c", "d", "e", "f")
+
+func createDeepPath(t testing.TB, bdb bolted.Database) {
+	err := bdb.Write(func(tx bolted.WriteTx) error {
+		for i := range deepPath {
+			tx.CreateMap(deepPath[:i+1])
+		}
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestBulkOperations(t *testing.T) {
+	bdb, cleanup := openEmptyDatabase(t, bolted.Options{})
+	defer cleanup()
+
+	createDeepPath(t, bdb)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	updates := bdb.Observe(ctx, deepPath.ToMatcher().AppendAnySubpathMatcher())
+	<-updates
+
+	t.Run("put many", func(t *testing.T) {
+		err := bdb.Write(func(tx bolted.WriteTx) error {
+			tx.PutMany(deepPath, []bolted.KeyValue{
+				{Key: "k1", Value: []byte{1}},
+				{Key: "k2", Value: []byte{2}},
+				{Key: "k1", Value: []byte{3}},
+			})
+			return nil
+		})
+		require.NoError(t, err)
+
+		require.Equal(t, bolted.ObservedChanges{
+			{Path: deepPath.Append("k1"), Type: bolted.ChangeTypeValueSet},
+			{Path: deepPath.Append("k2"), Type: bolted.ChangeTypeValueSet},
+		}, <-updates)
+
+		err = bdb.Read(func(tx bolted.ReadTx) error {
+			require.Equal(t, uint64(2), tx.GetSizeOf(deepPath))
+			require.Equal(t, [][]byte{{3}, {2}}, tx.GetMany(deepPath, []string{"k1", "k2"}))
+			return nil
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("get many of missing value", func(t *testing.T) {
+		err := bdb.Read(func(tx bolted.ReadTx) error {
+			tx.GetMany(deepPath, []string{"k1", "missing"})
+			return nil
+		})
+		require.Error(t, err)
+	})
+
+	t.Run("put many conflicting with map", func(t *testing.T) {
+		err := bdb.Write(func(tx bolted.WriteTx) error {
+			tx.CreateMap(deepPath.Append("m"))
+			tx.PutMany(deepPath, []bolted.KeyValue{{Key: "m", Value: []byte{1}}})
+			return nil
+		})
+		require.True(t, bolted.IsConflict(err))
+	})
+
+	t.Run("delete many", func(t *testing.T) {
+		err := bdb.Write(func(tx bolted.WriteTx) error {
+			tx.CreateMap(deepPath.Append("m"))
+			tx.DeleteMany(deepPath, []string{"k1", "m"})
+			return nil
+		})
+		require.NoError(t, err)
+
+		require.Equal(t, bolted.ObservedChanges{
+			{Path: deepPath.Append("k1"), Type: bolted.ChangeTypeDeleted},
+			{Path: deepPath.Append("m"), Type: bolted.ChangeTypeDeleted},
+		}, <-updates)
+
+		err = bdb.Read(func(tx bolted.ReadTx) error {
+			require.Equal(t, uint64(1), tx.GetSizeOf(deepPath))
+			require.False(t, tx.Exists(deepPath.Append("k1")))
+			return nil
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("delete many of missing key", func(t *testing.T) {
+		err := bdb.Write(func(tx bolted.WriteTx) error {
+			tx.DeleteMany(deepPath, []string{"missing"})
+			return nil
+		})
+		require.True(t, bolted.IsNotFound(err))
+	})
+}
+
+func TestBucketCacheInvalidation(t *testing.T) {
+	bdb, cleanup := openEmptyDatabase(t, bolted.Options{})
+	defer cleanup()
+
+	createDeepPath(t, bdb)
+
+	err := bdb.Write(func(tx bolted.WriteTx) error {
+		tx.Put(deepPath.Append("x"), []byte{1})
+
+		tx.Delete(deepPath[:2])
+		require.False(t, tx.Exists(deepPath.Append("x")))
+
+		for i := 2; i <= len(deepPath); i++ {
+			tx.CreateMap(deepPath[:i])
+		}
+		require.False(t, tx.Exists(deepPath.Append("x")))
+
+		tx.Put(deepPath.Append("y"), []byte{2})
+		return nil
+	})
+	require.NoError(t, err)
+
+	err = bdb.Read(func(tx bolted.ReadTx) error {
+		require.Equal(t, []byte{2}, tx.Get(deepPath.Append("y")))
+		require.False(t, tx.Exists(deepPath.Append("x")))
+		return nil
+	})
+	require.NoError(t, err)
+
+	err = bdb.Write(func(tx bolted.WriteTx) error {
+		tx.Put(deepPath.Append("z"), []byte{3})
+		tx.DeleteMany(deepPath[:1], []string{"b"})
+		tx.Put(deepPath.Append("z"), []byte{3})
+		return nil
+	})
+	require.Error(t, err)
+}
+
+func BenchmarkPutDeepPath(b *testing.B) {
+	bdb, cleanup := openEmptyDatabase(b, bolted.Options{})
+	defer cleanup()
+
+	createDeepPath(b, bdb)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err := bdb.Write(func(tx bolted.WriteTx) error {
+			for j := 0; j < 1000; j++ {
+				tx.Put(deepPath.Append(fmt.Sprintf("%04d", j)), []byte{1})
+			}
+			return nil
+		})
+		require.NoError(b, err)
+	}
+}
+
+func BenchmarkPutManyDeepPath(b *testing.B) {
+	bdb, cleanup := openEmptyDatabase(b, bolted.Options{})
+	defer cleanup()
+
+	createDeepPath(b, bdb)
+
+	kvs := make([]bolted.KeyValue, 1000)
+	for j := range kvs {
+		kvs[j] = bolted.KeyValue{Key: fmt.Sprintf("%04d", j), Value: []byte{1}}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err := bdb.Write(func(tx bolted.WriteTx) error {
+			tx.PutMany(deepPath, kvs)
+			return nil
+		})
+		require.NoError(b, err)
+	}
+}
@@ -35,10 +35,9 @@ var Command = &cli.Command{
 		if err != nil {
 			return fmt.Errorf("while parsing path %s: %w", p, err)
 		}
-		db, err := bolted.Open(sourceFile, 0700, bolted.Options{
+		db, err := bolted.OpenReadOnly(sourceFile, bolted.Options{
 			Options: bbolt.Options{
-				Timeout:  c.Duration("open-timeout"),
-				ReadOnly: true,
+				Timeout: c.Duration("open-timeout"),
 			},
 		})
 
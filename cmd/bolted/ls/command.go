@@ -39,10 +39,9 @@ var Command = &cli.Command{
 			return fmt.Errorf("while parsing path %s: %w", p, err)
 		}
 
-		db, err := bolted.Open(sourceFile, 0700, bolted.Options{
+		db, err := bolted.OpenReadOnly(sourceFile, bolted.Options{
 			Options: bbolt.Options{
-				Timeout:  c.Duration("open-timeout"),
-				ReadOnly: true,
+				Timeout: c.Duration("open-timeout"),
 			},
 		})
 
@@ -54,7 +53,7 @@ var Command = &cli.Command{
 			for it := tx.Iterate(dbp); !it.IsDone(); it.Next() {
 
 				suffix := ""
-				if it.GetValue() == nil {
+				if it.IsMap() {
 					suffix = "/"
 				}
 				fmt.Println(it.GetKey() + suffix)
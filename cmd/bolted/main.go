@@ -7,6 +7,8 @@ import (
 	"github.com/draganm/bolted/cmd/bolted/cat"
 	"github.com/draganm/bolted/cmd/bolted/compact"
 	"github.com/draganm/bolted/cmd/bolted/ls"
+	"github.com/draganm/bolted/cmd/bolted/rotatekey"
+	"github.com/draganm/bolted/cmd/bolted/verifychain"
 	"github.com/urfave/cli/v2"
 )
 
@@ -19,6 +21,8 @@ func main() {
 			compact.Command,
 			ls.Command,
 			cat.Command,
+			rotatekey.Command,
+			verifychain.Command,
 		},
 	}
 	err := app.Run(os.Args)
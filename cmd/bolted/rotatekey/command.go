@@ -0,0 +1,100 @@
+package rotatekey
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/draganm/bolted"
+	"github.com/draganm/bolted/dbpath"
+	"github.com/urfave/cli/v2"
+	"go.etcd.io/bbolt"
+)
+
+var Command = &cli.Command{
+	Name:      "rotate-key",
+	Usage:     "re-encrypt values with the current key",
+	ArgsUsage: "<database file>",
+	Flags: []cli.Flag{
+		&cli.StringSliceFlag{
+			Usage:    "encryption key in the form <key id>=<hex encoded key>, can be repeated",
+			Name:     "key",
+			EnvVars:  []string{"KEYS"},
+			Required: true,
+		},
+		&cli.StringFlag{
+			Usage:    "id of the key to encrypt values with",
+			Name:     "current-key-id",
+			EnvVars:  []string{"CURRENT_KEY_ID"},
+			Required: true,
+		},
+		&cli.StringFlag{
+			Usage:    "matcher of encrypted paths",
+			Name:     "encrypted",
+			EnvVars:  []string{"ENCRYPTED"},
+			Required: true,
+		},
+		&cli.IntFlag{
+			Usage:   "number of values re-encrypted in one transaction",
+			Name:    "batch-size",
+			EnvVars: []string{"BATCH_SIZE"},
+			Value:   1000,
+		},
+		&cli.DurationFlag{
+			Usage:   "timeout for opening the database",
+			Name:    "open-timeout",
+			Value:   500 * time.Millisecond,
+			EnvVars: []string{"OPEN_TIMEOUT"},
+		},
+	},
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 1 {
+			return fmt.Errorf("db file must be provided")
+		}
+
+		keys := map[string][]byte{}
+		for _, k := range c.StringSlice("key") {
+			parts := strings.SplitN(k, "=", 2)
+			if len(parts) != 2 {
+				return fmt.Errorf("key must be in the form <key id>=<hex encoded key>")
+			}
+			key, err := hex.DecodeString(parts[1])
+			if err != nil {
+				return fmt.Errorf("while decoding key %s: %w", parts[0], err)
+			}
+			keys[parts[0]] = key
+		}
+
+		encrypted, err := dbpath.ParseMatcher(c.String("encrypted"))
+		if err != nil {
+			return fmt.Errorf("while parsing matcher %s: %w", c.String("encrypted"), err)
+		}
+
+		db, err := bolted.Open(c.Args().Get(0), 0700, bolted.Options{
+			Options: bbolt.Options{
+				Timeout: c.Duration("open-timeout"),
+			},
+			Encrypted: encrypted,
+			KeyProvider: bolted.KeyRing{
+				Current: c.String("current-key-id"),
+				Keys:    keys,
+			},
+		})
+
+		if err != nil {
+			return fmt.Errorf("while opening database: %w", err)
+		}
+
+		defer db.Close()
+
+		n, err := db.ReencryptValues(c.Context, c.Int("batch-size"))
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("re-encrypted %d values\n", n)
+
+		return nil
+	},
+}
@@ -0,0 +1,88 @@
+package verifychain
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/draganm/bolted"
+	"github.com/urfave/cli/v2"
+	"go.etcd.io/bbolt"
+)
+
+var Command = &cli.Command{
+	Name:      "verify-chain",
+	Usage:     "verify the hash chain of commits recorded in the audit log",
+	ArgsUsage: "<database file>",
+	Flags: []cli.Flag{
+		&cli.StringSliceFlag{
+			Usage:   "expected hash of a commit in the form <tx id>=<hex encoded hash>, can be repeated",
+			Name:    "expect",
+			EnvVars: []string{"EXPECT"},
+		},
+		&cli.DurationFlag{
+			Usage:   "timeout for opening the database",
+			Name:    "open-timeout",
+			Value:   500 * time.Millisecond,
+			EnvVars: []string{"OPEN_TIMEOUT"},
+		},
+	},
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 1 {
+			return fmt.Errorf("db file must be provided")
+		}
+
+		expected := map[uint64][]byte{}
+		for _, e := range c.StringSlice("expect") {
+			parts := strings.SplitN(e, "=", 2)
+			if len(parts) != 2 {
+				return fmt.Errorf("expected hash must be in the form <tx id>=<hex encoded hash>")
+			}
+			txID, err := strconv.ParseUint(parts[0], 10, 64)
+			if err != nil {
+				return fmt.Errorf("while parsing tx id %s: %w", parts[0], err)
+			}
+			hash, err := hex.DecodeString(parts[1])
+			if err != nil {
+				return fmt.Errorf("while decoding hash of tx %d: %w", txID, err)
+			}
+			expected[txID] = hash
+		}
+
+		db, err := bolted.OpenReadOnly(c.Args().Get(0), bolted.Options{
+			Options: bbolt.Options{
+				Timeout: c.Duration("open-timeout"),
+			},
+		})
+
+		if err != nil {
+			return fmt.Errorf("while opening database: %w", err)
+		}
+
+		defer db.Close()
+
+		chain := db.(bolted.CommitChain)
+
+		head, err := chain.VerifyCommitChain(c.Context)
+		if err != nil {
+			return err
+		}
+
+		for txID, hash := range expected {
+			actual, err := chain.CommitHash(txID)
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(actual, hash) {
+				return fmt.Errorf("hash of tx %d is %x, expected %x", txID, actual, hash)
+			}
+		}
+
+		fmt.Printf("verified %d commits, last commit: tx %d %x\n", head.Commits, head.TxID, head.Hash)
+
+		return nil
+	},
+}
@@ -0,0 +1,385 @@
+package bolted
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/draganm/bolted/dbpath"
+)
+
+// Every audit record is chained to the previous one by storing the hash of the previous record's hash
+// and the encoded record under the record's key in a bucket next to the root bucket.
+// The hash of the first record is chained to a hash of zeros.
+// Encoded records include digests of the bytes stored for the values written by the transaction,
+// so the chain covers the written values as well.
+const commitChainBucketName = "bolted:chain"
+
+// The key of the latest audit record of every transaction ID is stored under the big endian ID
+// in a separate bucket, so hashes of commits can be looked up without scanning the audit log.
+// IDs can repeat after the file was compacted.
+const commitIndexBucketName = "bolted:chain-tx"
+
+var ErrBrokenCommitChain = errors.New("broken commit chain")
+
+func IsBrokenCommitChain(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	return errors.Is(err, ErrBrokenCommitChain)
+}
+
+// CommitChainHead describes the last commit of a verified commit chain.
+type CommitChainHead struct {
+	// Commits is the number of commits in the chain.
+	Commits uint64
+	// TxID is the ID of the last commit's transaction.
+	TxID uint64
+	// Hash is the hash of the last commit, or nil if the chain is empty.
+	Hash []byte
+}
+
+func commitHash(previous, record []byte) []byte {
+	h := sha256.New()
+	h.Write(previous)
+	h.Write(record)
+	return h.Sum(nil)
+}
+
+// valueDigest is the SHA-256 of the bytes stored for the value under the stored path.
+type valueDigest struct {
+	path   dbpath.Path
+	digest []byte
+}
+
+// storedDigest returns the SHA-256 of the bytes stored for the value or blob under key of the bucket.
+// It returns false if there is no value or blob.
+func storedDigest(bucket storageBucket, key []byte) ([]byte, bool) {
+	h := sha256.New()
+
+	v := bucket.Get(key)
+	if v != nil {
+		h.Write(v)
+		return h.Sum(nil), true
+	}
+
+	b := bucket.Bucket(key)
+	if !isBlob(b) {
+		return nil, false
+	}
+
+	size := blobSize(b)
+	for i, n := uint64(0), uint64(0); n < size; i++ {
+		chunk := b.Get(blobChunkKey(i))
+		if chunk == nil {
+			break
+		}
+		h.Write(chunk)
+		n += uint64(len(chunk))
+	}
+
+	return h.Sum(nil), true
+}
+
+// valueDigests returns digests of the values stored under the paths.
+func (w *writeTx) valueDigests(paths []dbpath.Path) ([]valueDigest, error) {
+	digests := []valueDigest{}
+	for _, p := range paths {
+		bucket, err := w.bucket(p[:len(p)-1])
+		if err != nil {
+			return nil, err
+		}
+
+		digest, exists := storedDigest(bucket, w.storedKey(p))
+		if !exists {
+			return nil, fmt.Errorf("value of %s not found", p.String())
+		}
+
+		digests = append(digests, valueDigest{path: w.storedPath(p), digest: digest})
+	}
+	return digests, nil
+}
+
+// chainCommit stores the hash of the audit record of the transaction stored under the key,
+// chained to the hash of the previous record.
+func (w *writeTx) chainCommit(txID uint64, key, record []byte) error {
+	chain := w.btx.Bucket([]byte(commitChainBucketName))
+	if chain == nil {
+		var err error
+		chain, err = w.btx.CreateBucket([]byte(commitChainBucketName))
+		if err != nil {
+			return err
+		}
+	}
+
+	previous := make([]byte, sha256.Size)
+	_, last := chain.Cursor().Last()
+	if last != nil {
+		previous = last
+	}
+
+	chain.SetFillPercent(1.0)
+
+	err := chain.Put(key, commitHash(previous, record))
+	if err != nil {
+		return err
+	}
+
+	index := w.btx.Bucket([]byte(commitIndexBucketName))
+	if index == nil {
+		index, err = w.btx.CreateBucket([]byte(commitIndexBucketName))
+		if err != nil {
+			return err
+		}
+	}
+
+	return index.Put(commitIndexKey(txID), key)
+}
+
+func commitIndexKey(txID uint64) []byte {
+	k := make([]byte, 8)
+	binary.BigEndian.PutUint64(k, txID)
+	return k
+}
+
+// commitHash returns the hash of the latest commit of the transaction with the ID.
+func (w *writeTx) commitHash(txID uint64) ([]byte, error) {
+	chain := w.btx.Bucket([]byte(commitChainBucketName))
+	index := w.btx.Bucket([]byte(commitIndexBucketName))
+	if chain == nil || index == nil {
+		return nil, ErrNotFound
+	}
+
+	k := index.Get(commitIndexKey(txID))
+	if k == nil {
+		return nil, ErrNotFound
+	}
+
+	h := chain.Get(k)
+	if h == nil {
+		return nil, fmt.Errorf("%w: audit record %d is not chained", ErrBrokenCommitChain, binary.BigEndian.Uint64(k))
+	}
+
+	return append([]byte(nil), h...), nil
+}
+
+// verifyCommitChain recomputes hashes of all audit records and compares them to the stored hashes.
+func (w *writeTx) verifyCommitChain() (CommitChainHead, error) {
+	head := CommitChainHead{}
+
+	records := w.btx.Bucket([]byte(auditBucketName))
+	chain := w.btx.Bucket([]byte(commitChainBucketName))
+
+	if records == nil && chain == nil {
+		return head, nil
+	}
+
+	if records == nil || chain == nil {
+		return head, fmt.Errorf("%w: audit records and hashes don't match", ErrBrokenCommitChain)
+	}
+
+	previous := make([]byte, sha256.Size)
+	latest := latestDigests{}
+	// latestCommits holds the key of the latest record of every transaction ID
+	latestCommits := map[uint64][]byte{}
+
+	rc := records.Cursor()
+	cc := chain.Cursor()
+
+	rk, rv := rc.First()
+	ck, cv := cc.First()
+
+	for ; rk != nil; rk, rv = rc.Next() {
+		w.checkForCancelledContext()
+
+		seq := binary.BigEndian.Uint64(rk)
+		if seq != head.Commits+1 {
+			return head, fmt.Errorf("%w: audit record %d is missing", ErrBrokenCommitChain, head.Commits+1)
+		}
+
+		if ck == nil || !bytes.Equal(ck, rk) {
+			return head, fmt.Errorf("%w: audit record %d is not chained", ErrBrokenCommitChain, seq)
+		}
+
+		h := commitHash(previous, rv)
+		if !bytes.Equal(h, cv) {
+			return head, fmt.Errorf("%w: hash of audit record %d doesn't match", ErrBrokenCommitChain, seq)
+		}
+
+		r, digests, err := decodeAuditRecord(rv)
+		if err != nil {
+			return head, fmt.Errorf("while decoding audit record %d: %w", seq, err)
+		}
+
+		latest.update(r.Changes, digests)
+		latestCommits[r.TxID] = rk
+
+		head = CommitChainHead{
+			Commits: seq,
+			TxID:    r.TxID,
+			Hash:    h,
+		}
+		previous = h
+
+		ck, cv = cc.Next()
+	}
+
+	if ck != nil {
+		return head, fmt.Errorf("%w: hash %d has no audit record", ErrBrokenCommitChain, binary.BigEndian.Uint64(ck))
+	}
+
+	err := w.verifyCommitIndex(latestCommits)
+	if err != nil {
+		return head, err
+	}
+
+	for _, d := range latest {
+		w.checkForCancelledContext()
+		err = w.verifyValueDigest(d)
+		if err != nil {
+			return head, err
+		}
+	}
+
+	return head, nil
+}
+
+// verifyCommitIndex checks that the index of commits points to the latest record of every transaction ID.
+func (w *writeTx) verifyCommitIndex(latestCommits map[uint64][]byte) error {
+	index := w.btx.Bucket([]byte(commitIndexBucketName))
+	if index == nil {
+		return fmt.Errorf("%w: index of commits is missing", ErrBrokenCommitChain)
+	}
+
+	indexed := 0
+	c := index.Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		w.checkForCancelledContext()
+		txID := binary.BigEndian.Uint64(k)
+		if !bytes.Equal(latestCommits[txID], v) {
+			return fmt.Errorf("%w: index of commit of tx %d doesn't match", ErrBrokenCommitChain, txID)
+		}
+		indexed++
+	}
+
+	if indexed != len(latestCommits) {
+		return fmt.Errorf("%w: index of commits is incomplete", ErrBrokenCommitChain)
+	}
+
+	return nil
+}
+
+// latestDigests holds the digest of the last write of every value that still exists according to the audit log,
+// keyed by the concatenated length prefixed elements of the stored path, so keys of a subtree share a prefix.
+type latestDigests map[string]valueDigest
+
+func latestDigestKey(p dbpath.Path) string {
+	var b []byte
+	for _, e := range p {
+		b = appendAuditString(b, e)
+	}
+	return string(b)
+}
+
+func (l latestDigests) update(changes ObservedChanges, digests []valueDigest) {
+	for _, c := range changes {
+		switch c.Type {
+		case ChangeTypeDeleted:
+			prefix := latestDigestKey(c.Path)
+			for k := range l {
+				if strings.HasPrefix(k, prefix) {
+					delete(l, k)
+				}
+			}
+		case ChangeTypeMapCreated:
+			delete(l, latestDigestKey(c.Path))
+		}
+	}
+
+	for _, d := range digests {
+		l[latestDigestKey(d.path)] = d
+	}
+}
+
+// verifyValueDigest checks that the bytes stored for the value match the digest of its last write,
+// and that content referenced by the value matches its hash.
+func (w *writeTx) verifyValueDigest(d valueDigest) error {
+	bucket := w.rootBucket
+	for _, e := range d.path[:len(d.path)-1] {
+		bucket = bucket.Bucket([]byte(e))
+		if bucket == nil {
+			return fmt.Errorf("%w: value %s was removed", ErrBrokenCommitChain, d.path.String())
+		}
+	}
+
+	key := []byte(d.path[len(d.path)-1])
+
+	digest, exists := storedDigest(bucket, key)
+	if !exists {
+		return fmt.Errorf("%w: value %s was removed", ErrBrokenCommitChain, d.path.String())
+	}
+
+	if !bytes.Equal(digest, d.digest) {
+		return fmt.Errorf("%w: value %s was changed", ErrBrokenCommitChain, d.path.String())
+	}
+
+	kind, hash, framed := parseFrame(bucket.Get(key))
+	if !framed || kind != frameContentRef {
+		return nil
+	}
+
+	content, err := w.loadContent(hash)
+	if err != nil {
+		return fmt.Errorf("%w: content of %s: %s", ErrBrokenCommitChain, d.path.String(), err)
+	}
+
+	h := sha256.Sum256(content)
+	if !bytes.Equal(h[:], hash) {
+		return fmt.Errorf("%w: content of %s was changed", ErrBrokenCommitChain, d.path.String())
+	}
+
+	return nil
+}
+
+// CommitChain is implemented by LocalDB and by read-only databases opened by OpenReadOnly.
+type CommitChain interface {
+	CommitHash(txID uint64) ([]byte, error)
+	VerifyCommitChain(ctx context.Context) (CommitChainHead, error)
+}
+
+// CommitHash returns the hash of the commit of the write transaction with the ID.
+// Commits are hashed together with the hash of the previous commit when Options.Audit is enabled,
+// so the hash of a commit covers the whole history, including the written values, up to that commit.
+// It returns ErrNotFound if there is no audit record of the transaction.
+func (b *LocalDB) CommitHash(txID uint64) (hash []byte, err error) {
+	err = b.Read(func(tx ReadTx) error {
+		hash, err = tx.(*writeTx).commitHash(txID)
+		if err != nil {
+			return fmt.Errorf("while getting hash of commit %d: %w", txID, err)
+		}
+		return nil
+	})
+	return hash, err
+}
+
+// VerifyCommitChain checks the hashes of all commits and the values written by their last commits,
+// and returns the last commit. It fails with ErrBrokenCommitChain if the stored records, hashes and values don't match.
+// Values changed while Options.Audit was disabled are reported as changed as well.
+// The chain is not keyed, so anyone able to write the file can change it and recompute all hashes.
+// Tampering is only detected by comparing the hash of a commit with a hash recorded outside of the file,
+// e.g. with the --expect flag of the verify-chain command.
+func (b *LocalDB) VerifyCommitChain(ctx context.Context) (head CommitChainHead, err error) {
+	err = b.ReadWithContext(ctx, func(tx ReadTx) error {
+		head, err = tx.(*writeTx).verifyCommitChain()
+		if err != nil {
+			return fmt.Errorf("while verifying commit chain: %w", err)
+		}
+		return nil
+	})
+	return head, err
+}
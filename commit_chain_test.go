@@ -0,0 +1,202 @@
+package bolted_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/draganm/bolted"
+	"github.com/draganm/bolted/dbpath"
+	"github.com/stretchr/testify/require"
+	"go.etcd.io/bbolt"
+)
+
+func TestCommitChain(t *testing.T) {
+	td, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(td)
+
+	dbFile := filepath.Join(td, "db")
+
+	db, err := bolted.Open(dbFile, 0660, bolted.Options{Audit: true})
+	require.NoError(t, err)
+
+	head, err := db.VerifyCommitChain(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, bolted.CommitChainHead{}, head)
+
+	txIDs := []uint64{}
+	for _, v := range []string{"1", "2", "3"} {
+		err = db.Write(func(tx bolted.WriteTx) error {
+			txIDs = append(txIDs, tx.ID())
+			tx.Put(dbpath.ToPath("v"), []byte(v))
+			return nil
+		})
+		require.NoError(t, err)
+	}
+
+	hashes := [][]byte{}
+	for _, txID := range txIDs {
+		h, err := db.CommitHash(txID)
+		require.NoError(t, err)
+		require.Len(t, h, 32)
+		hashes = append(hashes, h)
+	}
+
+	t.Run("hashes of commits differ", func(t *testing.T) {
+		require.NotEqual(t, hashes[0], hashes[1])
+		require.NotEqual(t, hashes[1], hashes[2])
+	})
+
+	t.Run("unknown transaction", func(t *testing.T) {
+		_, err := db.CommitHash(txIDs[2] + 100)
+		require.True(t, bolted.IsNotFound(err))
+	})
+
+	t.Run("chain is verified", func(t *testing.T) {
+		head, err := db.VerifyCommitChain(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, bolted.CommitChainHead{Commits: 3, TxID: txIDs[2], Hash: hashes[2]}, head)
+	})
+
+	require.NoError(t, db.Close())
+
+	t.Run("chain of a read-only database", func(t *testing.T) {
+		db, err := bolted.OpenReadOnly(dbFile, bolted.Options{})
+		require.NoError(t, err)
+		defer db.Close()
+
+		chain, isChain := db.(bolted.CommitChain)
+		require.True(t, isChain)
+
+		head, err := chain.VerifyCommitChain(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, hashes[2], head.Hash)
+
+		h, err := chain.CommitHash(txIDs[1])
+		require.NoError(t, err)
+		require.Equal(t, hashes[1], h)
+	})
+
+	tamper := func(t *testing.T, fn func(audit, chain, root *bbolt.Bucket) error) {
+		tamperedFile := filepath.Join(td, t.Name())
+		require.NoError(t, os.MkdirAll(filepath.Dir(tamperedFile), 0700))
+
+		data, err := ioutil.ReadFile(dbFile)
+		require.NoError(t, err)
+		require.NoError(t, ioutil.WriteFile(tamperedFile, data, 0660))
+
+		bdb, err := bbolt.Open(tamperedFile, 0660, nil)
+		require.NoError(t, err)
+		err = bdb.Update(func(tx *bbolt.Tx) error {
+			return fn(tx.Bucket([]byte("bolted:audit")), tx.Bucket([]byte("bolted:chain")), tx.Bucket([]byte("root")))
+		})
+		require.NoError(t, err)
+		require.NoError(t, bdb.Close())
+
+		db, err := bolted.Open(tamperedFile, 0660, bolted.Options{})
+		require.NoError(t, err)
+		defer db.Close()
+
+		_, err = db.VerifyCommitChain(context.Background())
+		require.True(t, bolted.IsBrokenCommitChain(err), "expected broken commit chain, got %v", err)
+	}
+
+	key := func(seq uint64) []byte {
+		k := make([]byte, 8)
+		binary.BigEndian.PutUint64(k, seq)
+		return k
+	}
+
+	t.Run("tampering is detected", func(t *testing.T) {
+		t.Run("changed record", func(t *testing.T) {
+			tamper(t, func(audit, chain, root *bbolt.Bucket) error {
+				v := append([]byte(nil), audit.Get(key(2))...)
+				v[len(v)-1] = 'x'
+				return audit.Put(key(2), v)
+			})
+		})
+
+		t.Run("removed record", func(t *testing.T) {
+			tamper(t, func(audit, chain, root *bbolt.Bucket) error {
+				err := audit.Delete(key(2))
+				if err != nil {
+					return err
+				}
+				return chain.Delete(key(2))
+			})
+		})
+
+		t.Run("rewritten hash", func(t *testing.T) {
+			tamper(t, func(audit, chain, root *bbolt.Bucket) error {
+				return chain.Put(key(1), make([]byte, 32))
+			})
+		})
+
+		t.Run("redirected index", func(t *testing.T) {
+			tamper(t, func(audit, chain, root *bbolt.Bucket) error {
+				return audit.Tx().Bucket([]byte("bolted:chain-tx")).Put(key(txIDs[0]), key(2))
+			})
+		})
+
+		t.Run("changed value", func(t *testing.T) {
+			tamper(t, func(audit, chain, root *bbolt.Bucket) error {
+				return root.Put([]byte("v"), []byte("x"))
+			})
+		})
+
+		t.Run("removed value", func(t *testing.T) {
+			tamper(t, func(audit, chain, root *bbolt.Bucket) error {
+				return root.Delete([]byte("v"))
+			})
+		})
+	})
+}
+
+func TestCommitChainOfReencryptedValues(t *testing.T) {
+	td, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(td)
+
+	dbFile := filepath.Join(td, "db")
+
+	open := func(t *testing.T, current string) *bolted.LocalDB {
+		db, err := bolted.Open(dbFile, 0660, bolted.Options{
+			Audit:     true,
+			Encrypted: dbpath.MustParseMatcher("*"),
+			KeyProvider: bolted.KeyRing{
+				Current: current,
+				Keys:    map[string][]byte{"k1": bytes.Repeat([]byte{1}, 32), "k2": bytes.Repeat([]byte{2}, 32)},
+			},
+		})
+		require.NoError(t, err)
+		return db
+	}
+
+	db := open(t, "k1")
+	err = db.Write(func(tx bolted.WriteTx) error {
+		tx.Put(dbpath.ToPath("v"), []byte("secret"))
+		return nil
+	})
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	db = open(t, "k2")
+	defer db.Close()
+
+	n, err := db.ReencryptValues(context.Background(), 10)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+
+	head, err := db.VerifyCommitChain(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), head.Commits)
+
+	records, err := db.AuditLog(context.Background(), bolted.AuditQuery{})
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+}
@@ -0,0 +1,92 @@
+package bolted
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+)
+
+// Compressed values are stored in a compressed frame holding the 8 byte big endian
+// logical size followed by the deflate compressed value.
+
+var errCorruptCompressedValue = errors.New("corrupt compressed value")
+
+var flateWriters = sync.Pool{
+	New: func() interface{} {
+		// default compression level is always valid
+		fw, _ := flate.NewWriter(nil, flate.DefaultCompression)
+		return fw
+	},
+}
+
+// compressValue returns the compressed frame of the value,
+// or the plain value if compression does not make it smaller.
+func compressValue(value []byte) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	buf.WriteString(frameMagic)
+	buf.WriteByte(byte(frameCompressed))
+
+	size := make([]byte, 8)
+	binary.BigEndian.PutUint64(size, uint64(len(value)))
+	buf.Write(size)
+
+	fw := flateWriters.Get().(*flate.Writer)
+	defer flateWriters.Put(fw)
+
+	fw.Reset(buf)
+
+	_, err := fw.Write(value)
+	if err != nil {
+		return nil, err
+	}
+
+	err = fw.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	if buf.Len() >= len(value) {
+		return plainValue(value), nil
+	}
+
+	return buf.Bytes(), nil
+}
+
+func compressedSize(payload []byte) (uint64, error) {
+	if len(payload) < 8 {
+		return 0, errCorruptCompressedValue
+	}
+	return binary.BigEndian.Uint64(payload), nil
+}
+
+func decompressValue(payload []byte) ([]byte, error) {
+	size, err := compressedSize(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	if size > math.MaxInt64-1 {
+		return nil, fmt.Errorf("%w: invalid size %d", errCorruptCompressedValue, size)
+	}
+
+	fr := flate.NewReader(bytes.NewReader(payload[8:]))
+	defer fr.Close()
+
+	// the size is not trusted for allocation, the buffer grows with the decompressed data
+	buf := bytes.NewBuffer(make([]byte, 0, min(size, 4*uint64(len(payload)))))
+	_, err = io.Copy(buf, io.LimitReader(fr, int64(size)+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", errCorruptCompressedValue, err)
+	}
+
+	if uint64(buf.Len()) != size {
+		return nil, fmt.Errorf("%w: expected %d bytes, got %d", errCorruptCompressedValue, size, buf.Len())
+	}
+
+	return buf.Bytes(), nil
+}
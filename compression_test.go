@@ -0,0 +1,194 @@
+package bolted_test
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/draganm/bolted"
+	"github.com/draganm/bolted/dbpath"
+	"github.com/stretchr/testify/require"
+	"go.etcd.io/bbolt"
+)
+
+func storedSize(t *testing.T, dbFile string, path dbpath.Path) int {
+	db, err := bbolt.Open(dbFile, 0660, nil)
+	require.NoError(t, err)
+	defer db.Close()
+
+	size := 0
+	err = db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("root"))
+		for _, e := range path[:len(path)-1] {
+			b = b.Bucket([]byte(e))
+		}
+		size = len(b.Get([]byte(path[len(path)-1])))
+		return nil
+	})
+	require.NoError(t, err)
+	return size
+}
+
+func TestCompressedValues(t *testing.T) {
+	td, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(td)
+
+	dbFile := filepath.Join(td, "db")
+
+	docs := dbpath.ToPath("docs")
+	uncompressedDoc := docs.Append("uncompressed")
+	compressedDoc := docs.Append("compressed")
+	randomDoc := docs.Append("random")
+
+	doc := bytes.Repeat([]byte(`{"name":"bolted","compressed":true},`), 1000)
+	random := randomBytes(1000)
+
+	t.Run("values stored before compression was enabled", func(t *testing.T) {
+		bdb, err := bolted.Open(dbFile, 0660, bolted.Options{})
+		require.NoError(t, err)
+
+		err = bdb.Write(func(tx bolted.WriteTx) error {
+			tx.CreateMap(docs)
+			tx.Put(uncompressedDoc, doc)
+			return nil
+		})
+		require.NoError(t, err)
+
+		require.NoError(t, bdb.Close())
+	})
+
+	t.Run("values stored after compression was enabled", func(t *testing.T) {
+		bdb, err := bolted.Open(dbFile, 0660, bolted.Options{
+			Compressed: dbpath.MustParseMatcher("docs/*"),
+		})
+		require.NoError(t, err)
+
+		err = bdb.Write(func(tx bolted.WriteTx) error {
+			tx.Put(compressedDoc, doc)
+			tx.Put(randomDoc, random)
+			return nil
+		})
+		require.NoError(t, err)
+
+		err = bdb.Read(func(tx bolted.ReadTx) error {
+			require.Equal(t, doc, tx.Get(uncompressedDoc))
+			require.Equal(t, doc, tx.Get(compressedDoc))
+			require.Equal(t, random, tx.Get(randomDoc))
+
+			require.Equal(t, uint64(len(doc)), tx.GetSizeOf(uncompressedDoc))
+			require.Equal(t, uint64(len(doc)), tx.GetSizeOf(compressedDoc))
+
+			it := tx.Iterate(docs)
+			require.Equal(t, "compressed", it.GetKey())
+			require.Equal(t, doc, it.GetValue())
+			require.Equal(t, uint64(len(doc)), it.GetSize())
+			return nil
+		})
+		require.NoError(t, err)
+
+		require.NoError(t, bdb.Close())
+	})
+
+	t.Run("only compressible values are stored compressed", func(t *testing.T) {
+		require.Equal(t, len(doc), storedSize(t, dbFile, uncompressedDoc))
+		require.Less(t, storedSize(t, dbFile, compressedDoc), len(doc)/10)
+		require.Equal(t, len(random), storedSize(t, dbFile, randomDoc))
+	})
+
+	t.Run("incompressible values looking like frames", func(t *testing.T) {
+		bdb, err := bolted.Open(dbFile, 0660, bolted.Options{
+			Compressed: dbpath.MustParseMatcher("docs/*"),
+		})
+		require.NoError(t, err)
+		defer bdb.Close()
+
+		framed := append([]byte("\x00bolted\x00\x02"), random...)
+
+		err = bdb.Write(func(tx bolted.WriteTx) error {
+			tx.Put(docs.Append("framed"), framed)
+			return nil
+		})
+		require.NoError(t, err)
+
+		err = bdb.Read(func(tx bolted.ReadTx) error {
+			require.Equal(t, framed, tx.Get(docs.Append("framed")))
+			return nil
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("sizes of compressed values are not trusted", func(t *testing.T) {
+		compressed := &bytes.Buffer{}
+		fw, err := flate.NewWriter(compressed, flate.DefaultCompression)
+		require.NoError(t, err)
+		_, err = fw.Write(doc)
+		require.NoError(t, err)
+		require.NoError(t, fw.Close())
+
+		cases := []struct {
+			name string
+			size uint64
+		}{
+			{name: "huge", size: 1 << 62},
+			{name: "too large", size: uint64(len(doc)) + 1},
+			{name: "too small", size: uint64(len(doc)) - 1},
+		}
+
+		for _, c := range cases {
+			t.Run(c.name, func(t *testing.T) {
+				header := make([]byte, 8)
+				binary.BigEndian.PutUint64(header, c.size)
+				v := append([]byte("\x00bolted\x00\x02"), header...)
+				v = append(v, compressed.Bytes()...)
+
+				db, err := bbolt.Open(dbFile, 0660, nil)
+				require.NoError(t, err)
+				err = db.Update(func(tx *bbolt.Tx) error {
+					return tx.Bucket([]byte("root")).Bucket([]byte("docs")).Put([]byte("corrupt"), v)
+				})
+				require.NoError(t, err)
+				require.NoError(t, db.Close())
+
+				bdb, err := bolted.Open(dbFile, 0660, bolted.Options{})
+				require.NoError(t, err)
+				defer bdb.Close()
+
+				err = bdb.Read(func(tx bolted.ReadTx) error {
+					tx.Get(docs.Append("corrupt"))
+					return nil
+				})
+				require.Error(t, err)
+			})
+		}
+	})
+}
+
+func TestCompressedContentAddressedValues(t *testing.T) {
+	db, cleanup := openEmptyDatabase(t, bolted.Options{
+		Compressed:       dbpath.MustParseMatcher("*"),
+		ContentAddressed: dbpath.MustParseMatcher("*"),
+	})
+	defer cleanup()
+
+	doc := bytes.Repeat([]byte("compressed and deduplicated "), 100)
+
+	err := db.Write(func(tx bolted.WriteTx) error {
+		tx.Put(dbpath.ToPath("a"), doc)
+		tx.Put(dbpath.ToPath("b"), doc)
+		return nil
+	})
+	require.NoError(t, err)
+
+	err = db.Read(func(tx bolted.ReadTx) error {
+		require.Equal(t, doc, tx.Get(dbpath.ToPath("a")))
+		require.Equal(t, doc, tx.Get(dbpath.ToPath("b")))
+		require.Equal(t, uint64(len(doc)), tx.GetSizeOf(dbpath.ToPath("b")))
+		return nil
+	})
+	require.NoError(t, err)
+}
@@ -0,0 +1,210 @@
+package bolted
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Content addressed values are stored once in a bucket next to the root bucket,
+// keyed by their SHA-256, with reference counts kept in a separate bucket.
+// Paths store a content reference frame holding the hash.
+const (
+	contentStoreBucketName = "bolted:content"
+	contentDataBucketName  = "data"
+	contentRefsBucketName  = "refs"
+)
+
+var errDanglingContentReference = errors.New("dangling content reference")
+
+func (w *writeTx) contentBuckets(create bool) (data storageBucket, refs storageBucket, err error) {
+	store := w.btx.Bucket([]byte(contentStoreBucketName))
+	if store == nil {
+		if !create {
+			return nil, nil, nil
+		}
+		store, err = w.btx.CreateBucket([]byte(contentStoreBucketName))
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	data = store.Bucket([]byte(contentDataBucketName))
+	refs = store.Bucket([]byte(contentRefsBucketName))
+
+	if data == nil && create {
+		data, err = store.CreateBucket([]byte(contentDataBucketName))
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if refs == nil && create {
+		refs, err = store.CreateBucket([]byte(contentRefsBucketName))
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return data, refs, nil
+}
+
+func contentRefCount(refs storageBucket, hash []byte) uint64 {
+	v := refs.Get(hash)
+	if v == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint64(v)
+}
+
+func setContentRefCount(refs storageBucket, hash []byte, count uint64) error {
+	v := make([]byte, 8)
+	binary.BigEndian.PutUint64(v, count)
+	return refs.Put(hash, v)
+}
+
+// storeContent stores the value in the content store if it is not already there,
+// increments its reference count and returns the reference to be stored under the path.
+func (w *writeTx) storeContent(value []byte) ([]byte, error) {
+	data, refs, err := w.contentBuckets(true)
+	if err != nil {
+		return nil, err
+	}
+
+	h := sha256.Sum256(value)
+	hash := h[:]
+
+	if data.Get(hash) == nil {
+		data.SetFillPercent(w.fillPercent)
+		err = data.Put(hash, value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = setContentRefCount(refs, hash, contentRefCount(refs, hash)+1)
+	if err != nil {
+		return nil, err
+	}
+
+	return frame(frameContentRef, hash), nil
+}
+
+func (w *writeTx) loadContent(hash []byte) ([]byte, error) {
+	data, _, err := w.contentBuckets(false)
+	if err != nil {
+		return nil, err
+	}
+
+	if data == nil {
+		return nil, errDanglingContentReference
+	}
+
+	v := data.Get(hash)
+	if v == nil {
+		return nil, errDanglingContentReference
+	}
+
+	return v, nil
+}
+
+// releaseValue decrements the reference count if the stored value is a content reference.
+// Unreferenced content is removed by the garbage collection.
+func (w *writeTx) releaseValue(v []byte) error {
+	kind, hash, framed := parseFrame(v)
+	if !framed || kind != frameContentRef {
+		return nil
+	}
+
+	_, refs, err := w.contentBuckets(false)
+	if err != nil {
+		return err
+	}
+
+	if refs == nil {
+		return errDanglingContentReference
+	}
+
+	count := contentRefCount(refs, hash)
+	if count == 0 {
+		return errDanglingContentReference
+	}
+
+	return setContentRefCount(refs, hash, count-1)
+}
+
+// releaseMap releases all values stored in the map and its descendants.
+func (w *writeTx) releaseMap(b storageBucket) error {
+	if w.btx.Bucket([]byte(contentStoreBucketName)) == nil {
+		// content store was never used, there can't be any references
+		return nil
+	}
+
+	if isBlob(b) {
+		return nil
+	}
+
+	c := b.Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		var err error
+		if v == nil {
+			err = w.releaseMap(b.Bucket(k))
+		} else {
+			err = w.releaseValue(v)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *writeTx) collectGarbage() (int, error) {
+	data, refs, err := w.contentBuckets(false)
+	if err != nil {
+		return 0, err
+	}
+
+	if data == nil {
+		return 0, nil
+	}
+
+	unreferenced := [][]byte{}
+
+	c := data.Cursor()
+	for k, _ := c.First(); k != nil; k, _ = c.Next() {
+		w.checkForCancelledContext()
+		if contentRefCount(refs, k) == 0 {
+			unreferenced = append(unreferenced, append([]byte(nil), k...))
+		}
+	}
+
+	for _, k := range unreferenced {
+		err = data.Delete(k)
+		if err != nil {
+			return 0, err
+		}
+		err = refs.Delete(k)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(unreferenced), nil
+}
+
+// CollectGarbage removes content that is not referenced by any path
+// and returns the number of removed values.
+func (b *LocalDB) CollectGarbage(ctx context.Context) (removed int, err error) {
+	err = b.WriteWithContext(ctx, func(tx WriteTx) error {
+		removed, err = tx.(*writeTx).collectGarbage()
+		if err != nil {
+			return fmt.Errorf("while collecting garbage: %w", err)
+		}
+		return nil
+	})
+	return removed, err
+}
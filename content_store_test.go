@@ -0,0 +1,148 @@
+package bolted_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/draganm/bolted"
+	"github.com/draganm/bolted/dbpath"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContentAddressedValues(t *testing.T) {
+	db, cleanup := openEmptyDatabase(t, bolted.Options{
+		ContentAddressed: dbpath.MustParseMatcher("attachments/*"),
+	})
+	defer cleanup()
+
+	bdb := db.(*bolted.LocalDB)
+
+	attachments := dbpath.ToPath("attachments")
+	a1 := attachments.Append("a1")
+	a2 := attachments.Append("a2")
+	a3 := attachments.Append("a3")
+
+	x := []byte("attachment x")
+	y := []byte("attachment y")
+
+	collectGarbage := func(t *testing.T) int {
+		removed, err := bdb.CollectGarbage(context.Background())
+		require.NoError(t, err)
+		return removed
+	}
+
+	t.Run("put values", func(t *testing.T) {
+		err := bdb.Write(func(tx bolted.WriteTx) error {
+			tx.CreateMap(attachments)
+			tx.Put(a1, x)
+			tx.Put(a2, x)
+			tx.PutMany(attachments, []bolted.KeyValue{{Key: "a3", Value: y}})
+			return nil
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("values are transparently resolved", func(t *testing.T) {
+		err := bdb.Read(func(tx bolted.ReadTx) error {
+			require.Equal(t, x, tx.Get(a1))
+			require.Equal(t, x, tx.Get(a2))
+			require.Equal(t, y, tx.Get(a3))
+			require.Equal(t, uint64(len(x)), tx.GetSizeOf(a1))
+			require.Equal(t, [][]byte{x, y}, tx.GetMany(attachments, []string{"a2", "a3"}))
+
+			err := tx.View(a1, func(v []byte) error {
+				require.Equal(t, x, v)
+				return nil
+			})
+			require.NoError(t, err)
+
+			it := tx.Iterate(attachments)
+			require.Equal(t, x, it.GetValue())
+			require.Equal(t, uint64(len(x)), it.GetSize())
+
+			values := [][]byte{}
+			err = tx.Walk(attachments, func(_ dbpath.Path, isMap bool, value []byte) error {
+				if !isMap {
+					values = append(values, value)
+				}
+				return nil
+			})
+			require.NoError(t, err)
+			require.Equal(t, [][]byte{x, x, y}, values)
+			return nil
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("plain values looking like content references are not resolved", func(t *testing.T) {
+		h := sha256.Sum256(x)
+		forged := append([]byte("\x00bolted\x00\x01"), h[:]...)
+
+		err := bdb.Write(func(tx bolted.WriteTx) error {
+			tx.Put(dbpath.ToPath("forged"), forged)
+			tx.PutMany(dbpath.Path{}, []bolted.KeyValue{{Key: "forged2", Value: forged}})
+			return nil
+		})
+		require.NoError(t, err)
+
+		err = bdb.Write(func(tx bolted.WriteTx) error {
+			require.Equal(t, forged, tx.Get(dbpath.ToPath("forged")))
+			require.Equal(t, forged, tx.Get(dbpath.ToPath("forged2")))
+			require.Equal(t, uint64(len(forged)), tx.GetSizeOf(dbpath.ToPath("forged")))
+			tx.Delete(dbpath.ToPath("forged"))
+			tx.Delete(dbpath.ToPath("forged2"))
+			return nil
+		})
+		require.NoError(t, err)
+
+		require.Equal(t, 0, collectGarbage(t))
+	})
+
+	t.Run("content referenced by other paths is kept", func(t *testing.T) {
+		err := bdb.Write(func(tx bolted.WriteTx) error {
+			tx.Delete(a1)
+			return nil
+		})
+		require.NoError(t, err)
+
+		require.Equal(t, 0, collectGarbage(t))
+
+		err = bdb.Read(func(tx bolted.ReadTx) error {
+			require.Equal(t, x, tx.Get(a2))
+			return nil
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("overwritten content is collected", func(t *testing.T) {
+		err := bdb.Write(func(tx bolted.WriteTx) error {
+			tx.Put(a2, y)
+			return nil
+		})
+		require.NoError(t, err)
+
+		require.Equal(t, 1, collectGarbage(t))
+		require.Equal(t, 0, collectGarbage(t))
+	})
+
+	t.Run("content of deleted maps is collected", func(t *testing.T) {
+		err := bdb.Write(func(tx bolted.WriteTx) error {
+			tx.Delete(attachments)
+			return nil
+		})
+		require.NoError(t, err)
+
+		require.Equal(t, 1, collectGarbage(t))
+	})
+
+	t.Run("values outside of the matcher are stored in place", func(t *testing.T) {
+		err := bdb.Write(func(tx bolted.WriteTx) error {
+			tx.Put(dbpath.ToPath("plain"), x)
+			return nil
+		})
+		require.NoError(t, err)
+
+		require.Equal(t, 0, collectGarbage(t))
+	})
+}
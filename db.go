@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"io"
+	"iter"
 
 	"github.com/draganm/bolted/dbpath"
 	"go.etcd.io/bbolt"
@@ -17,6 +18,8 @@ type Database interface {
 	WriteWithContext(context.Context, func(tx WriteTx) error) error
 
 	Observe(ctx context.Context, path dbpath.Matcher) <-chan ObservedChanges
+	// Sub returns a Database whose paths, matchers and observed changes are relative to prefix.
+	Sub(prefix dbpath.Path) Database
 	Close() error
 	Stats() (*bbolt.Stats, error)
 }
@@ -25,13 +28,30 @@ type WriteTx interface {
 	CreateMap(path dbpath.Path)
 	Delete(path dbpath.Path)
 	Put(path dbpath.Path, value []byte)
+	PutMany(parent dbpath.Path, kvs []KeyValue)
+	PutReader(path dbpath.Path, r io.Reader)
+	DeleteMany(parent dbpath.Path, keys []string)
 	SetFillPercent(float64)
 	ReadTx
 }
 
 type ReadTx interface {
 	Get(path dbpath.Path) []byte
+	View(path dbpath.Path, fn func(v []byte) error) error
+	GetInto(path dbpath.Path, buf []byte) []byte
+	GetMany(parent dbpath.Path, keys []string) [][]byte
+	OpenReader(path dbpath.Path) io.ReadSeeker
 	Iterate(path dbpath.Path) Iterator
+	IterateRange(path dbpath.Path, from, to Bound) Iterator
+	IterateRangeReverse(path dbpath.Path, from, to Bound) Iterator
+	IteratePrefix(path dbpath.Path, prefix string) Iterator
+	IteratePrefixReverse(path dbpath.Path, prefix string) Iterator
+	Page(path dbpath.Path, token string, limit int) PageResult
+	PageReverse(path dbpath.Path, token string, limit int) PageResult
+	Walk(root dbpath.Path, fn WalkFunc) error
+	WalkWithOptions(root dbpath.Path, opts WalkOptions, fn WalkFunc) error
+	Find(m dbpath.Matcher) []dbpath.Path
+	FindWithFunc(m dbpath.Matcher, fn FindFunc) error
 	Exists(path dbpath.Path) bool
 	IsMap(path dbpath.Path) bool
 	GetSizeOf(path dbpath.Path) uint64
@@ -43,14 +63,20 @@ type ReadTx interface {
 
 type Iterator interface {
 	GetKey() string
+	GetKeyBytes() []byte
 	GetValue() []byte
 	GetRawValue() []byte
+	IsMap() bool
+	GetSize() uint64
 	IsDone() bool
 	Prev()
 	Next()
 	Seek(key string)
 	First()
 	Last()
+	All() iter.Seq2[string, []byte]
+	Keys() iter.Seq[string]
+	Values() iter.Seq[[]byte]
 }
 
 var ErrNotFound = errors.New("not found")
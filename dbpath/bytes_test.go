@@ -0,0 +1,54 @@
+package dbpath_test
+
+import (
+	"testing"
+
+	"github.com/draganm/bolted/dbpath"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBytesRoundTrip(t *testing.T) {
+	cases := []struct {
+		title    string
+		elements [][]byte
+	}{
+		{
+			title:    "no elements",
+			elements: [][]byte{},
+		},
+		{
+			title:    "printable elements",
+			elements: [][]byte{[]byte("foo"), []byte("bar")},
+		},
+		{
+			title:    "empty element",
+			elements: [][]byte{[]byte("foo"), {}, []byte("bar")},
+		},
+		{
+			title:    "binary elements",
+			elements: [][]byte{{0x00, 0xff, '/', '%'}, {0xc3, 0x28}},
+		},
+		{
+			title:    "element that looks like an escape",
+			elements: [][]byte{[]byte("%25"), []byte("%")},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			p := dbpath.FromBytes(tc.elements...)
+			require.Equal(t, tc.elements, p.Bytes())
+
+			parsed, err := dbpath.Parse(p.String())
+			require.NoError(t, err)
+			require.Equal(t, tc.elements, parsed.Bytes())
+		})
+	}
+}
+
+func TestFromBytesCopiesElements(t *testing.T) {
+	e := []byte{1, 2, 3}
+	p := dbpath.FromBytes(e)
+	e[0] = 42
+	require.Equal(t, []byte{1, 2, 3}, p.ElementBytes(0))
+}
@@ -17,6 +17,30 @@ func (p Path) Append(elements ...string) Path {
 	return cp
 }
 
+// AppendBytes returns a copy of the path extended with raw byte elements.
+func (p Path) AppendBytes(elements ...[]byte) Path {
+	cp := make(Path, len(p)+len(elements))
+	copy(cp, p)
+	for i, e := range elements {
+		cp[len(p)+i] = string(e)
+	}
+	return cp
+}
+
+// ElementBytes returns a copy of the i-th path element as raw bytes.
+func (p Path) ElementBytes(i int) []byte {
+	return []byte(p[i])
+}
+
+// Bytes returns copies of all path elements as raw bytes.
+func (p Path) Bytes() [][]byte {
+	res := make([][]byte, len(p))
+	for i, e := range p {
+		res[i] = []byte(e)
+	}
+	return res
+}
+
 func (p Path) String() string {
 	return Join(p...)
 }
@@ -73,10 +97,21 @@ func ToPath(p ...string) Path {
 	return Path(p)
 }
 
+// FromBytes creates a path from raw byte elements, e.g. hashes or binary IDs.
+// Elements are copied, so the caller is free to reuse the slices.
+func FromBytes(elements ...[]byte) Path {
+	return Path{}.AppendBytes(elements...)
+}
+
 var NilPath = Path(nil)
 
 const Separator = "/"
 
+// emptyPart is the escaped form of an empty path element.
+// It is never produced by escaping a non-empty element and is not a valid URL escape,
+// so it can't be confused with any other element.
+const emptyPart = "%"
+
 func Split(path string) ([]string, error) {
 
 	parts := strings.Split(path, Separator)
@@ -84,6 +119,10 @@ func Split(path string) ([]string, error) {
 	res := []string{}
 
 	for i, p := range parts {
+		if p == emptyPart {
+			res = append(res, "")
+			continue
+		}
 		up, err := UnescapePart(p)
 		if err != nil {
 			return nil, fmt.Errorf("while unescaping part at position %d: %q: %w", i, p, err)
@@ -117,10 +156,18 @@ func Append(pth string, parts ...string) string {
 	return strings.Join(escaped, Separator)
 }
 
+// EscapePart escapes a single path element.
+// Every byte sequence, including invalid UTF-8 and the empty element, has a distinct escaped form.
 func EscapePart(part string) string {
+	if part == "" {
+		return emptyPart
+	}
 	return url.PathEscape(part)
 }
 
 func UnescapePart(part string) (string, error) {
+	if part == emptyPart {
+		return "", nil
+	}
 	return url.PathUnescape(part)
 }
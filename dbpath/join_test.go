@@ -38,6 +38,11 @@ func TestJoin(t *testing.T) {
 			parts:          []string{"foo", "bar/"},
 			expectedResult: "foo/bar%2F",
 		},
+		{
+			title:          "empty element",
+			parts:          []string{"foo", "", "bar"},
+			expectedResult: "foo/%/bar",
+		},
 	}
 
 	for _, tc := range cases {
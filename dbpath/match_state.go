@@ -0,0 +1,98 @@
+package dbpath
+
+import "sort"
+
+// MatchState tracks matching of a path one element at a time.
+// It allows callers traversing a tree to prune subtrees that can't contain a matching path.
+type MatchState struct {
+	m         Matcher
+	positions []int
+}
+
+// Start returns the state of the matcher before consuming any path element.
+func (m Matcher) Start() MatchState {
+	s := MatchState{m: m}
+	s.positions = s.addWithClosure(nil, 0)
+	return s
+}
+
+// addWithClosure adds the position and all positions reachable without consuming an element.
+func (s MatchState) addWithClosure(positions []int, pos int) []int {
+	for {
+		for _, p := range positions {
+			if p == pos {
+				return positions
+			}
+		}
+		positions = append(positions, pos)
+		if pos == len(s.m) {
+			return positions
+		}
+		_, isAnySubpath := s.m[pos].(anySubpathMatcher)
+		if !isAnySubpath {
+			return positions
+		}
+		pos++
+	}
+}
+
+// Step returns the state after consuming the path element e.
+func (s MatchState) Step(e string) MatchState {
+	next := MatchState{m: s.m}
+	for _, pos := range s.positions {
+		if pos == len(s.m) {
+			continue
+		}
+		switch me := s.m[pos].(type) {
+		case anySubpathMatcher:
+			next.positions = next.addWithClosure(next.positions, pos)
+		case elementMatcher:
+			if me.matchesElement(e) {
+				next.positions = next.addWithClosure(next.positions, pos+1)
+			}
+		}
+	}
+	return next
+}
+
+// Matches returns true if the consumed path is matched by the matcher.
+func (s MatchState) Matches() bool {
+	for _, pos := range s.positions {
+		if pos == len(s.m) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsDead returns true if no extension of the consumed path can be matched.
+func (s MatchState) IsDead() bool {
+	for _, pos := range s.positions {
+		if pos < len(s.m) {
+			return false
+		}
+	}
+	return true
+}
+
+// ExactCandidates returns the sorted list of elements that can follow the consumed path,
+// if all of them are known exactly. It returns false if any other element might be matched.
+func (s MatchState) ExactCandidates() ([]string, bool) {
+	candidates := []string{}
+	seen := map[string]bool{}
+	for _, pos := range s.positions {
+		if pos == len(s.m) {
+			continue
+		}
+		e, isExact := s.m[pos].(exactMatcher)
+		if !isExact {
+			return nil, false
+		}
+		if !seen[string(e)] {
+			seen[string(e)] = true
+			candidates = append(candidates, string(e))
+		}
+	}
+	sort.Strings(candidates)
+	return candidates, true
+}
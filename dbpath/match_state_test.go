@@ -0,0 +1,80 @@
+package dbpath_test
+
+import (
+	"testing"
+
+	"github.com/draganm/bolted/dbpath"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchStateAgreesWithMatches(t *testing.T) {
+	matchers := []string{
+		"",
+		"**",
+		"a/**",
+		"a/*/c",
+		"**/c",
+		"a/**/**/c",
+		"{a,b}/!c",
+	}
+
+	paths := []dbpath.Path{
+		dbpath.ToPath(),
+		dbpath.ToPath("a"),
+		dbpath.ToPath("a", "b"),
+		dbpath.ToPath("a", "b", "c"),
+		dbpath.ToPath("b", "c"),
+		dbpath.ToPath("a", "x", "y", "c"),
+	}
+
+	for _, ms := range matchers {
+		m := dbpath.MustParseMatcher(ms)
+		for _, p := range paths {
+			s := m.Start()
+			for _, e := range p {
+				s = s.Step(e)
+			}
+			require.Equal(t, m.Matches(p), s.Matches(), "matcher %q path %q", ms, p.String())
+		}
+	}
+}
+
+func TestMatchState(t *testing.T) {
+	t.Run("exact candidates", func(t *testing.T) {
+		s := dbpath.MustParseMatcher("tenants/*/{users,groups}/*/email").Start()
+
+		candidates, exact := s.ExactCandidates()
+		require.True(t, exact)
+		require.Equal(t, []string{"tenants"}, candidates)
+
+		s = s.Step("tenants")
+		_, exact = s.ExactCandidates()
+		require.False(t, exact)
+
+		s = s.Step("t1")
+		_, exact = s.ExactCandidates()
+		require.False(t, exact)
+
+		s = s.Step("users").Step("u1")
+		candidates, exact = s.ExactCandidates()
+		require.True(t, exact)
+		require.Equal(t, []string{"email"}, candidates)
+		require.False(t, s.Matches())
+
+		s = s.Step("email")
+		require.True(t, s.Matches())
+		require.True(t, s.IsDead())
+	})
+
+	t.Run("dead state", func(t *testing.T) {
+		s := dbpath.MustParseMatcher("a/b").Start().Step("x")
+		require.True(t, s.IsDead())
+		require.False(t, s.Matches())
+	})
+
+	t.Run("any subpath is never dead", func(t *testing.T) {
+		s := dbpath.MustParseMatcher("a/**").Start().Step("a").Step("x").Step("y")
+		require.False(t, s.IsDead())
+		require.True(t, s.Matches())
+	})
+}
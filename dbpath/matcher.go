@@ -1,67 +1,174 @@
 package dbpath
 
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
 type Matcher []matcherElement
 
+// Matches returns true if the path is matched by the matcher.
+// Paths are matched in O(len(p)*len(m)) steps, without backtracking over any subpath matchers.
 func (m Matcher) Matches(p Path) bool {
-	if len(m) == 0 && len(p) == 0 {
+	if !m.hasAnySubpathMatcher() {
+		if len(m) != len(p) {
+			return false
+		}
+		for i, me := range m {
+			if !me.(elementMatcher).matchesElement(p[i]) {
+				return false
+			}
+		}
 		return true
 	}
 
-	if len(m) == 0 {
-		return false
+	// current and next mark positions in the matcher reachable after consuming a prefix of the path
+	current := make([]bool, len(m)+1)
+	next := make([]bool, len(m)+1)
+
+	m.reach(current, 0)
+
+	for _, e := range p {
+		clear(next)
+		alive := false
+		for pos, me := range m {
+			if !current[pos] {
+				continue
+			}
+			switch me := me.(type) {
+			case anySubpathMatcher:
+				m.reach(next, pos)
+				alive = true
+			case elementMatcher:
+				if me.matchesElement(e) {
+					m.reach(next, pos+1)
+					alive = true
+				}
+			}
+		}
+		if !alive {
+			return false
+		}
+		current, next = next, current
 	}
 
-	return m[0].matches(p, m[1:])
+	return current[len(m)]
+}
+
+// reach marks the position and all positions reachable from it without consuming an element.
+func (m Matcher) reach(positions []bool, pos int) {
+	for {
+		positions[pos] = true
+		if pos == len(m) {
+			return
+		}
+		_, isAnySubpath := m[pos].(anySubpathMatcher)
+		if !isAnySubpath {
+			return
+		}
+		pos++
+	}
 }
 
 type matcherElement interface {
-	matches(p Path, rhs Matcher) bool
+	String() string
 }
 
-type exactMatcher string
+// elementMatcher is implemented by matcher elements that match exactly one path element.
+type elementMatcher interface {
+	matcherElement
+	matchesElement(e string) bool
+}
 
-func (e exactMatcher) matches(p Path, rhs Matcher) bool {
-	if len(p) == 0 {
-		return false
-	}
+type exactMatcher string
 
-	if string(p[0]) != string(e) {
-		return false
-	}
+func (e exactMatcher) matchesElement(el string) bool {
+	return el == string(e)
+}
 
-	return rhs.Matches(p[1:])
+func (e exactMatcher) String() string {
+	return EscapePart(string(e))
 }
 
 type anyElementMatcher struct{}
 
-func (a anyElementMatcher) matches(p Path, rhs Matcher) bool {
-	if len(p) == 0 {
-		return false
-	}
+func (a anyElementMatcher) matchesElement(string) bool {
+	return true
+}
 
-	return rhs.Matches(p[1:])
+func (a anyElementMatcher) String() string {
+	return anyElementToken
 }
 
 type anySubpathMatcher struct{}
 
-func (a anySubpathMatcher) matches(p Path, rhs Matcher) bool {
-
-	if len(rhs) == 0 {
-		return true
+func (m Matcher) hasAnySubpathMatcher() bool {
+	for _, me := range m {
+		_, isAnySubpath := me.(anySubpathMatcher)
+		if isAnySubpath {
+			return true
+		}
 	}
+	return false
+}
+
+func (a anySubpathMatcher) String() string {
+	return anySubpathToken
+}
+
+type prefixMatcher string
+
+func (pm prefixMatcher) matchesElement(e string) bool {
+	return strings.HasPrefix(e, string(pm))
+}
+
+func (pm prefixMatcher) String() string {
+	return EscapePart(string(pm)) + anyElementToken
+}
+
+type regexpMatcher struct {
+	source   string
+	anchored *regexp.Regexp
+}
+
+func (rm regexpMatcher) matchesElement(e string) bool {
+	return rm.anchored.MatchString(e)
+}
+
+func (rm regexpMatcher) String() string {
+	return regexpStartToken + escapeRegexp(rm.source) + regexpEndToken
+}
+
+type alternativesMatcher []string
 
-	for i := range p {
-		if rhs.Matches(p[i:]) {
+func (am alternativesMatcher) matchesElement(e string) bool {
+	for _, a := range am {
+		if a == e {
 			return true
 		}
 	}
 	return false
+}
 
-	// if len(p) == 1 {
-	// 	return true
-	// }
+func (am alternativesMatcher) String() string {
+	escaped := make([]string, len(am))
+	for i, a := range am {
+		escaped[i] = EscapePart(a)
+	}
+	return alternativesStartToken + strings.Join(escaped, alternativesSeparator) + alternativesEndToken
+}
+
+type negatedMatcher struct {
+	em elementMatcher
+}
+
+func (nm negatedMatcher) matchesElement(e string) bool {
+	return !nm.em.matchesElement(e)
+}
 
-	// return false
+func (nm negatedMatcher) String() string {
+	return negationToken + nm.em.String()
 }
 
 func (m Matcher) AppendExactMatcher(e string) Matcher {
@@ -84,3 +191,53 @@ func (m Matcher) AppendAnySubpathMatcher() Matcher {
 	cp[len(m)] = anySubpathMatcher{}
 	return cp
 }
+
+// AppendPrefixMatcher appends a matcher for a single element starting with prefix.
+func (m Matcher) AppendPrefixMatcher(prefix string) Matcher {
+	cp := make(Matcher, len(m)+1)
+	copy(cp, m)
+	cp[len(m)] = prefixMatcher(prefix)
+	return cp
+}
+
+// AppendRegexpMatcher appends a matcher for a single element that is entirely matched by re.
+func (m Matcher) AppendRegexpMatcher(re *regexp.Regexp) Matcher {
+	cp := make(Matcher, len(m)+1)
+	copy(cp, m)
+	cp[len(m)] = newRegexpMatcher(re)
+	return cp
+}
+
+func newRegexpMatcher(re *regexp.Regexp) regexpMatcher {
+	return regexpMatcher{
+		source: re.String(),
+		// re is already valid, so wrapping it can't fail
+		anchored: regexp.MustCompile(`^(?:` + re.String() + `)$`),
+	}
+}
+
+// AppendAlternativesMatcher appends a matcher for a single element equal to one of the alternatives.
+func (m Matcher) AppendAlternativesMatcher(alternatives ...string) Matcher {
+	cp := make(Matcher, len(m)+1)
+	copy(cp, m)
+	cp[len(m)] = alternativesMatcher(append([]string(nil), alternatives...))
+	return cp
+}
+
+// AppendNegatedMatcher appends a matcher for a single element that is not matched by element.
+// element must consist of exactly one single element matcher, it panics otherwise.
+func (m Matcher) AppendNegatedMatcher(element Matcher) Matcher {
+	if len(element) != 1 {
+		panic(fmt.Errorf("negated matcher must have exactly one element, got %d", len(element)))
+	}
+
+	em, isElementMatcher := element[0].(elementMatcher)
+	if !isElementMatcher {
+		panic(fmt.Errorf("%s can't be negated", element[0].String()))
+	}
+
+	cp := make(Matcher, len(m)+1)
+	copy(cp, m)
+	cp[len(m)] = negatedMatcher{em: em}
+	return cp
+}
@@ -0,0 +1,182 @@
+package dbpath
+
+// MatcherIndex compiles many matchers into a shared trie,
+// so the matchers matching a path can be found in time proportional to the path depth
+// instead of evaluating every matcher.
+// Exact elements are looked up by key, other element matchers shared between
+// matchers are evaluated once per trie node.
+// MatcherIndex is not safe for concurrent use.
+type MatcherIndex struct {
+	root *indexNode
+}
+
+type indexNode struct {
+	exact      map[string]*indexNode
+	elements   []*indexEdge
+	anySubpath *indexNode
+	// loop is set for nodes reached through an any subpath matcher,
+	// they consume any number of additional elements
+	loop bool
+	ids  map[int]struct{}
+}
+
+type indexEdge struct {
+	key  string
+	em   elementMatcher
+	node *indexNode
+}
+
+func NewMatcherIndex() *MatcherIndex {
+	return &MatcherIndex{
+		root: &indexNode{},
+	}
+}
+
+// Add registers the matcher under the given id.
+func (mi *MatcherIndex) Add(m Matcher, id int) {
+	n := mi.root
+	for _, me := range m {
+		n = n.child(me, true)
+	}
+	if n.ids == nil {
+		n.ids = map[int]struct{}{}
+	}
+	n.ids[id] = struct{}{}
+}
+
+// Remove unregisters the matcher previously added with the given id.
+func (mi *MatcherIndex) Remove(m Matcher, id int) {
+	mi.root.remove(m, id)
+}
+
+// Match returns ids of all matchers matching the path.
+func (mi *MatcherIndex) Match(p Path) []int {
+	current := map[*indexNode]struct{}{}
+	mi.root.addWithClosure(current)
+
+	for _, e := range p {
+		if len(current) == 0 {
+			return nil
+		}
+		next := map[*indexNode]struct{}{}
+		for n := range current {
+			n.step(e, next)
+		}
+		current = next
+	}
+
+	found := map[int]struct{}{}
+	for n := range current {
+		for id := range n.ids {
+			found[id] = struct{}{}
+		}
+	}
+
+	if len(found) == 0 {
+		return nil
+	}
+
+	ids := make([]int, 0, len(found))
+	for id := range found {
+		ids = append(ids, id)
+	}
+
+	return ids
+}
+
+func (n *indexNode) child(me matcherElement, create bool) *indexNode {
+	switch e := me.(type) {
+	case anySubpathMatcher:
+		if n.anySubpath == nil && create {
+			n.anySubpath = &indexNode{loop: true}
+		}
+		return n.anySubpath
+	case exactMatcher:
+		c := n.exact[string(e)]
+		if c == nil && create {
+			if n.exact == nil {
+				n.exact = map[string]*indexNode{}
+			}
+			c = &indexNode{}
+			n.exact[string(e)] = c
+		}
+		return c
+	case elementMatcher:
+		key := e.String()
+		for _, ed := range n.elements {
+			if ed.key == key {
+				return ed.node
+			}
+		}
+		if !create {
+			return nil
+		}
+		ed := &indexEdge{key: key, em: e, node: &indexNode{}}
+		n.elements = append(n.elements, ed)
+		return ed.node
+	default:
+		panic("unsupported matcher element " + me.String())
+	}
+}
+
+func (n *indexNode) isEmpty() bool {
+	return len(n.exact) == 0 && len(n.elements) == 0 && n.anySubpath == nil && len(n.ids) == 0
+}
+
+// remove returns true if the node has become empty and can be pruned.
+func (n *indexNode) remove(m Matcher, id int) bool {
+	if len(m) == 0 {
+		delete(n.ids, id)
+		return n.isEmpty()
+	}
+
+	c := n.child(m[0], false)
+	if c == nil || !c.remove(m[1:], id) {
+		return false
+	}
+
+	switch e := m[0].(type) {
+	case anySubpathMatcher:
+		n.anySubpath = nil
+	case exactMatcher:
+		delete(n.exact, string(e))
+	default:
+		key := e.String()
+		for i, ed := range n.elements {
+			if ed.key == key {
+				n.elements = append(n.elements[:i], n.elements[i+1:]...)
+				break
+			}
+		}
+	}
+
+	return n.isEmpty()
+}
+
+// addWithClosure adds the node and all nodes reachable without consuming an element.
+func (n *indexNode) addWithClosure(states map[*indexNode]struct{}) {
+	for ; n != nil; n = n.anySubpath {
+		_, seen := states[n]
+		if seen {
+			return
+		}
+		states[n] = struct{}{}
+	}
+}
+
+func (n *indexNode) step(e string, next map[*indexNode]struct{}) {
+	if n.loop {
+		n.addWithClosure(next)
+	}
+
+	c := n.exact[e]
+	if c != nil {
+		c.addWithClosure(next)
+	}
+
+	for _, ed := range n.elements {
+		if ed.em.matchesElement(e) {
+			ed.node.addWithClosure(next)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package dbpath_test
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/draganm/bolted/dbpath"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatcherIndex(t *testing.T) {
+	matchers := []string{
+		"",
+		"**",
+		"foo",
+		"foo/**",
+		"foo/*",
+		"foo/bar",
+		"**/bar",
+		"**/**/bar",
+		"foo/**/baz/**",
+		"foo/{bar,baz}",
+		"foo/!bar",
+		"foo/ba*",
+		"foo/([a-z]+)/x",
+		"foo/(ba.)",
+	}
+
+	paths := []dbpath.Path{
+		dbpath.ToPath(),
+		dbpath.ToPath("foo"),
+		dbpath.ToPath("bar"),
+		dbpath.ToPath("foo", "bar"),
+		dbpath.ToPath("foo", "baz"),
+		dbpath.ToPath("foo", "qux"),
+		dbpath.ToPath("foo", "bar", "x"),
+		dbpath.ToPath("foo", "x", "baz", "y", "z"),
+		dbpath.ToPath("a", "b", "bar"),
+	}
+
+	idx := dbpath.NewMatcherIndex()
+	for i, m := range matchers {
+		idx.Add(dbpath.MustParseMatcher(m), i)
+	}
+
+	for _, p := range paths {
+		t.Run(p.String(), func(t *testing.T) {
+			expected := []int{}
+			for i, m := range matchers {
+				if dbpath.MustParseMatcher(m).Matches(p) {
+					expected = append(expected, i)
+				}
+			}
+
+			actual := idx.Match(p)
+			if actual == nil {
+				actual = []int{}
+			}
+			sort.Ints(actual)
+			require.Equal(t, expected, actual)
+		})
+	}
+
+	t.Run("remove", func(t *testing.T) {
+		for i, m := range matchers {
+			idx.Remove(dbpath.MustParseMatcher(m), i)
+		}
+
+		for _, p := range paths {
+			require.Empty(t, idx.Match(p))
+		}
+	})
+}
+
+func TestMatcherIndexSharedMatcher(t *testing.T) {
+	idx := dbpath.NewMatcherIndex()
+	m := dbpath.MustParseMatcher("foo/*")
+
+	idx.Add(m, 1)
+	idx.Add(m, 2)
+
+	matching := idx.Match(dbpath.ToPath("foo", "bar"))
+	sort.Ints(matching)
+	require.Equal(t, []int{1, 2}, matching)
+
+	idx.Remove(m, 1)
+	require.Equal(t, []int{2}, idx.Match(dbpath.ToPath("foo", "bar")))
+}
+
+func perEntityMatchers(n int) []dbpath.Matcher {
+	matchers := make([]dbpath.Matcher, n)
+	for i := range matchers {
+		matchers[i] = dbpath.ToPath("tenants", "t1", "users", fmt.Sprintf("user-%d", i)).ToMatcher().AppendAnySubpathMatcher()
+	}
+	return matchers
+}
+
+func BenchmarkMatchersLinear(b *testing.B) {
+	matchers := perEntityMatchers(5000)
+	p := dbpath.ToPath("tenants", "t1", "users", "user-2500", "profile", "email")
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, m := range matchers {
+			m.Matches(p)
+		}
+	}
+}
+
+func BenchmarkMatcherIndex(b *testing.B) {
+	idx := dbpath.NewMatcherIndex()
+	for i, m := range perEntityMatchers(5000) {
+		idx.Add(m, i)
+	}
+	p := dbpath.ToPath("tenants", "t1", "users", "user-2500", "profile", "email")
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		idx.Match(p)
+	}
+}
@@ -0,0 +1,149 @@
+package dbpath
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Tokens used in the string form of a Matcher.
+// Escaping a literal path element never produces them, since all of them are percent-encoded.
+const (
+	anyElementToken        = "*"
+	anySubpathToken        = "**"
+	negationToken          = "!"
+	alternativesStartToken = "{"
+	alternativesEndToken   = "}"
+	alternativesSeparator  = ","
+	regexpStartToken       = "("
+	regexpEndToken         = ")"
+)
+
+// ParseMatcher parses the string form of a matcher, e.g. "users/*/profile/**".
+// Elements are separated and escaped the same way as in Split.
+// Following elements have a special meaning:
+//
+//	"*"        any single element
+//	"**"       any (possibly empty) subpath
+//	"log-*"    any single element starting with "log-"
+//	"{a,b,c}"  any of the single elements a, b or c
+//	"(re)"     any single element entirely matched by the regular expression re
+//	"!x"       any single element not matched by the single element matcher x
+func ParseMatcher(s string) (Matcher, error) {
+	parts := strings.Split(s, Separator)
+
+	m := Matcher{}
+
+	for i, p := range parts {
+		switch p {
+		case "":
+			continue
+		case anyElementToken:
+			m = m.AppendAnyElementMatcher()
+		case anySubpathToken:
+			m = m.AppendAnySubpathMatcher()
+		default:
+			em, err := parseElementMatcher(p)
+			if err != nil {
+				return nil, fmt.Errorf("while parsing matcher part at position %d: %q: %w", i, p, err)
+			}
+			m = append(m, em)
+		}
+	}
+
+	return m, nil
+}
+
+func parseElementMatcher(p string) (elementMatcher, error) {
+	switch {
+	case p == anyElementToken:
+		return anyElementMatcher{}, nil
+	case strings.HasPrefix(p, negationToken):
+		em, err := parseElementMatcher(strings.TrimPrefix(p, negationToken))
+		if err != nil {
+			return nil, err
+		}
+		return negatedMatcher{em: em}, nil
+	case strings.HasPrefix(p, alternativesStartToken) && strings.HasSuffix(p, alternativesEndToken):
+		inner := p[len(alternativesStartToken) : len(p)-len(alternativesEndToken)]
+		if inner == "" {
+			return alternativesMatcher{}, nil
+		}
+		alternatives := strings.Split(inner, alternativesSeparator)
+		for i, a := range alternatives {
+			ua, err := UnescapePart(a)
+			if err != nil {
+				return nil, err
+			}
+			alternatives[i] = ua
+		}
+		return alternativesMatcher(alternatives), nil
+	case strings.HasPrefix(p, regexpStartToken) && strings.HasSuffix(p, regexpEndToken) && len(p) >= len(regexpStartToken)+len(regexpEndToken):
+		source, err := UnescapePart(p[len(regexpStartToken) : len(p)-len(regexpEndToken)])
+		if err != nil {
+			return nil, err
+		}
+		re, err := regexp.Compile(source)
+		if err != nil {
+			return nil, err
+		}
+		return newRegexpMatcher(re), nil
+	}
+
+	prefix := strings.TrimSuffix(p, anyElementToken)
+	if strings.Contains(prefix, anyElementToken) {
+		return nil, errors.New("'*' can only be used at the end of an element")
+	}
+
+	up, err := UnescapePart(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(prefix) != len(p) {
+		return prefixMatcher(up), nil
+	}
+
+	return exactMatcher(up), nil
+}
+
+// escapeRegexp escapes characters of a regular expression that would otherwise
+// be interpreted as a separator or an escape sequence.
+func escapeRegexp(source string) string {
+	return strings.NewReplacer("%", "%25", Separator, "%2F").Replace(source)
+}
+
+func MustParseMatcher(s string) Matcher {
+	m, err := ParseMatcher(s)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+func (m Matcher) String() string {
+	parts := make([]string, len(m))
+	for i, me := range m {
+		parts[i] = me.String()
+	}
+	return strings.Join(parts, Separator)
+}
+
+func (m Matcher) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+func (m *Matcher) UnmarshalText(text []byte) error {
+	pm, err := ParseMatcher(string(text))
+	if err != nil {
+		return err
+	}
+	*m = pm
+	return nil
+}
+
+// Set implements flag.Value so matchers can be used as command line flags.
+func (m *Matcher) Set(s string) error {
+	return m.UnmarshalText([]byte(s))
+}
@@ -0,0 +1,138 @@
+package dbpath_test
+
+import (
+	"encoding/json"
+	"regexp"
+	"testing"
+
+	"github.com/draganm/bolted/dbpath"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMatcher(t *testing.T) {
+	cases := []struct {
+		title         string
+		input         string
+		expected      dbpath.Matcher
+		canonical     string
+		expectedError string
+	}{
+		{
+			title:     "empty",
+			input:     "",
+			expected:  dbpath.Matcher{},
+			canonical: "",
+		},
+		{
+			title:     "root",
+			input:     "/",
+			expected:  dbpath.Matcher{},
+			canonical: "",
+		},
+		{
+			title:     "exact elements",
+			input:     "/foo/bar/",
+			expected:  dbpath.ToPath("foo", "bar").ToMatcher(),
+			canonical: "foo/bar",
+		},
+		{
+			title:     "any element and any subpath",
+			input:     "users/*/profile/**",
+			expected:  dbpath.ToPath("users").ToMatcher().AppendAnyElementMatcher().AppendExactMatcher("profile").AppendAnySubpathMatcher(),
+			canonical: "users/*/profile/**",
+		},
+		{
+			title:     "escaped star is an exact element",
+			input:     "%2A/%2A%2A",
+			expected:  dbpath.ToPath("*", "**").ToMatcher(),
+			canonical: "%2A/%2A%2A",
+		},
+		{
+			title:     "escaped slash",
+			input:     "foo%2Fbar",
+			expected:  dbpath.ToPath("foo/bar").ToMatcher(),
+			canonical: "foo%2Fbar",
+		},
+		{
+			title:     "prefix",
+			input:     "logs/log-*",
+			expected:  dbpath.ToPath("logs").ToMatcher().AppendPrefixMatcher("log-"),
+			canonical: "logs/log-*",
+		},
+		{
+			title:     "escaped prefix",
+			input:     "a%2Ab*",
+			expected:  dbpath.Matcher{}.AppendPrefixMatcher("a*b"),
+			canonical: "a%2Ab*",
+		},
+		{
+			title:     "alternatives",
+			input:     "{a,b%2Cc,%}",
+			expected:  dbpath.Matcher{}.AppendAlternativesMatcher("a", "b,c", ""),
+			canonical: "{a,b%2Cc,%}",
+		},
+		{
+			title:     "regexp",
+			input:     "([0-9]+%2F.*)",
+			expected:  dbpath.Matcher{}.AppendRegexpMatcher(regexp.MustCompile("[0-9]+/.*")),
+			canonical: "([0-9]+%2F.*)",
+		},
+		{
+			title:     "negation",
+			input:     "!tmp-*/!{a,b}/!([0-9]+)",
+			expected:  dbpath.Matcher{}.AppendNegatedMatcher(dbpath.Matcher{}.AppendPrefixMatcher("tmp-")).AppendNegatedMatcher(dbpath.Matcher{}.AppendAlternativesMatcher("a", "b")).AppendNegatedMatcher(dbpath.Matcher{}.AppendRegexpMatcher(regexp.MustCompile("[0-9]+"))),
+			canonical: "!tmp-*/!{a,b}/!([0-9]+)",
+		},
+		{
+			title:         "negated any subpath",
+			input:         "!**",
+			expectedError: "while parsing matcher part at position 0: \"!**\": '*' can only be used at the end of an element",
+		},
+		{
+			title:         "invalid regexp",
+			input:         "([)",
+			expectedError: "while parsing matcher part at position 0: \"([)\": error parsing regexp: missing closing ]: `[`",
+		},
+		{
+			title:         "invalid escape",
+			input:         "foo/%%",
+			expectedError: "while parsing matcher part at position 1: \"%%\": invalid URL escape \"%%\"",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			m, err := dbpath.ParseMatcher(tc.input)
+			if tc.expectedError != "" {
+				require.EqualError(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, m)
+			require.Equal(t, tc.canonical, m.String())
+
+			reparsed, err := dbpath.ParseMatcher(m.String())
+			require.NoError(t, err)
+			require.Equal(t, m, reparsed)
+		})
+	}
+}
+
+func TestMatcherJSON(t *testing.T) {
+	type config struct {
+		Watch dbpath.Matcher `json:"watch"`
+	}
+
+	c := config{Watch: dbpath.MustParseMatcher("users/*/profile/**")}
+
+	d, err := json.Marshal(c)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"watch":"users/*/profile/**"}`, string(d))
+
+	var parsed config
+	err = json.Unmarshal(d, &parsed)
+	require.NoError(t, err)
+	require.Equal(t, c, parsed)
+
+	require.True(t, parsed.Watch.Matches(dbpath.ToPath("users", "1", "profile", "name")))
+}
@@ -1,6 +1,7 @@
 package dbpath_test
 
 import (
+	"regexp"
 	"testing"
 
 	"github.com/draganm/bolted/dbpath"
@@ -154,6 +155,78 @@ func TestMatcher(t *testing.T) {
 			path:    dbpath.ToPath("foo"),
 			matches: false,
 		},
+		{
+			name:    "two any subpath matchers match empty path",
+			matcher: dbpath.Matcher{}.AppendAnySubpathMatcher().AppendAnySubpathMatcher(),
+			path:    dbpath.ToPath(),
+			matches: true,
+		},
+		{
+			name:    "any subpath matchers around exact element match element in the middle",
+			matcher: dbpath.Matcher{}.AppendAnySubpathMatcher().AppendExactMatcher("abc").AppendAnySubpathMatcher(),
+			path:    dbpath.ToPath("foo", "abc", "bar"),
+			matches: true,
+		},
+		{
+			name:    "prefix matcher matches element with the prefix",
+			matcher: dbpath.Matcher{}.AppendPrefixMatcher("log-"),
+			path:    dbpath.ToPath("log-2023"),
+			matches: true,
+		},
+		{
+			name:    "prefix matcher matches element equal to the prefix",
+			matcher: dbpath.Matcher{}.AppendPrefixMatcher("log-"),
+			path:    dbpath.ToPath("log-"),
+			matches: true,
+		},
+		{
+			name:    "prefix matcher does not match element without the prefix",
+			matcher: dbpath.Matcher{}.AppendPrefixMatcher("log-"),
+			path:    dbpath.ToPath("audit-2023"),
+			matches: false,
+		},
+		{
+			name:    "regexp matcher matches entire element",
+			matcher: dbpath.Matcher{}.AppendRegexpMatcher(regexp.MustCompile("[0-9]+")),
+			path:    dbpath.ToPath("123"),
+			matches: true,
+		},
+		{
+			name:    "regexp matcher does not match part of the element",
+			matcher: dbpath.Matcher{}.AppendRegexpMatcher(regexp.MustCompile("[0-9]+")),
+			path:    dbpath.ToPath("a123"),
+			matches: false,
+		},
+		{
+			name:    "alternatives matcher matches one of the alternatives",
+			matcher: dbpath.Matcher{}.AppendAlternativesMatcher("a", "b", "c").AppendExactMatcher("x"),
+			path:    dbpath.ToPath("b", "x"),
+			matches: true,
+		},
+		{
+			name:    "alternatives matcher does not match other element",
+			matcher: dbpath.Matcher{}.AppendAlternativesMatcher("a", "b", "c"),
+			path:    dbpath.ToPath("d"),
+			matches: false,
+		},
+		{
+			name:    "negated matcher matches other element",
+			matcher: dbpath.Matcher{}.AppendNegatedMatcher(dbpath.Matcher{}.AppendPrefixMatcher("tmp-")),
+			path:    dbpath.ToPath("data"),
+			matches: true,
+		},
+		{
+			name:    "negated matcher does not match negated element",
+			matcher: dbpath.Matcher{}.AppendNegatedMatcher(dbpath.Matcher{}.AppendPrefixMatcher("tmp-")),
+			path:    dbpath.ToPath("tmp-1"),
+			matches: false,
+		},
+		{
+			name:    "negated matcher does not match empty path",
+			matcher: dbpath.Matcher{}.AppendNegatedMatcher(dbpath.ToPath("abc").ToMatcher()),
+			path:    dbpath.ToPath(),
+			matches: false,
+		},
 	}
 
 	for _, c := range cases {
@@ -163,3 +236,23 @@ func TestMatcher(t *testing.T) {
 		})
 	}
 }
+
+func TestNegatedMatcherPanicsForAnySubpath(t *testing.T) {
+	require.Panics(t, func() {
+		dbpath.Matcher{}.AppendNegatedMatcher(dbpath.Matcher{}.AppendAnySubpathMatcher())
+	})
+}
+
+func BenchmarkMatcherWithManyAnySubpaths(b *testing.B) {
+	// every element can be matched by several any subpath matchers, which is the worst case for backtracking
+	m := dbpath.MustParseMatcher("**/x/**/x/**/x/**/x/**/y")
+	p := make(dbpath.Path, 64)
+	for i := range p {
+		p[i] = "x"
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.Matches(p)
+	}
+}
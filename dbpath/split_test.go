@@ -45,6 +45,12 @@ func TestSplit(t *testing.T) {
 			expectedResult: []string{" "},
 			expectedError:  "",
 		},
+		{
+			title:          "escaped empty element",
+			path:           "foo/%/bar",
+			expectedResult: []string{"foo", "", "bar"},
+			expectedError:  "",
+		},
 		{
 			title:          "invalid",
 			path:           "%%/",
@@ -0,0 +1,182 @@
+package dbpath
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// variablePrefix marks a named element in the string form of a Template.
+// Literal elements starting with it are escaped when a template is turned into a string.
+const variablePrefix = ":"
+
+// Template is a path pattern with named elements, e.g. "tenants/:tenant/users/:user/**".
+// Each named element matches exactly one path element and binds it to its name.
+// Apart from named elements, templates support the same syntax as ParseMatcher,
+// with the restriction that "**" can only be the last element.
+type Template []templateElement
+
+type templateElement struct {
+	name string
+	m    matcherElement
+}
+
+func (te templateElement) String() string {
+	if te.name != "" {
+		return variablePrefix + te.name
+	}
+
+	s := te.m.String()
+	if strings.HasPrefix(s, variablePrefix) {
+		return "%3A" + strings.TrimPrefix(s, variablePrefix)
+	}
+	return s
+}
+
+func ParseTemplate(s string) (Template, error) {
+	parts := strings.Split(s, Separator)
+
+	t := Template{}
+	names := map[string]bool{}
+
+	for i, p := range parts {
+		if p == "" {
+			continue
+		}
+
+		if len(t) > 0 {
+			_, lastIsAnySubpath := t[len(t)-1].m.(anySubpathMatcher)
+			if lastIsAnySubpath {
+				return nil, fmt.Errorf("while parsing template part at position %d: %q: %w", i, p, errors.New("'**' can only be the last element"))
+			}
+		}
+
+		switch {
+		case strings.HasPrefix(p, variablePrefix):
+			name := strings.TrimPrefix(p, variablePrefix)
+			if name == "" {
+				return nil, fmt.Errorf("while parsing template part at position %d: %q: %w", i, p, errors.New("variable name must not be empty"))
+			}
+			if names[name] {
+				return nil, fmt.Errorf("while parsing template part at position %d: %q: %w", i, p, fmt.Errorf("duplicate variable %q", name))
+			}
+			names[name] = true
+			t = append(t, templateElement{name: name, m: anyElementMatcher{}})
+		case p == anySubpathToken:
+			t = append(t, templateElement{m: anySubpathMatcher{}})
+		default:
+			em, err := parseElementMatcher(p)
+			if err != nil {
+				return nil, fmt.Errorf("while parsing template part at position %d: %q: %w", i, p, err)
+			}
+			t = append(t, templateElement{m: em})
+		}
+	}
+
+	return t, nil
+}
+
+func MustParseTemplate(s string) Template {
+	t, err := ParseTemplate(s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func (t Template) String() string {
+	parts := make([]string, len(t))
+	for i, te := range t {
+		parts[i] = te.String()
+	}
+	return strings.Join(parts, Separator)
+}
+
+func (t Template) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+func (t *Template) UnmarshalText(text []byte) error {
+	pt, err := ParseTemplate(string(text))
+	if err != nil {
+		return err
+	}
+	*t = pt
+	return nil
+}
+
+// Matcher returns a matcher matching the same paths as the template.
+// It can be used to observe changes of paths matching the template.
+func (t Template) Matcher() Matcher {
+	m := make(Matcher, len(t))
+	for i, te := range t {
+		m[i] = te.m
+	}
+	return m
+}
+
+// Variables returns names of the template variables in the order they appear.
+func (t Template) Variables() []string {
+	names := []string{}
+	for _, te := range t {
+		if te.name != "" {
+			names = append(names, te.name)
+		}
+	}
+	return names
+}
+
+// Match returns values bound to the template variables if p matches the template.
+func (t Template) Match(p Path) (map[string]string, bool) {
+	values := map[string]string{}
+
+	for i, te := range t {
+		_, isAnySubpath := te.m.(anySubpathMatcher)
+		if isAnySubpath {
+			// '**' is always the last element
+			return values, true
+		}
+
+		if i >= len(p) {
+			return nil, false
+		}
+
+		em := te.m.(elementMatcher)
+		if !em.matchesElement(p[i]) {
+			return nil, false
+		}
+
+		if te.name != "" {
+			values[te.name] = p[i]
+		}
+	}
+
+	if len(p) != len(t) {
+		return nil, false
+	}
+
+	return values, true
+}
+
+// Render creates a concrete path by replacing the template variables with values.
+// Only templates consisting of variables and exact elements can be rendered.
+func (t Template) Render(values map[string]string) (Path, error) {
+	p := make(Path, len(t))
+	for i, te := range t {
+		if te.name != "" {
+			v, found := values[te.name]
+			if !found {
+				return nil, fmt.Errorf("while rendering template %s: %w", t.String(), fmt.Errorf("missing value for variable %q", te.name))
+			}
+			p[i] = v
+			continue
+		}
+
+		e, isExact := te.m.(exactMatcher)
+		if !isExact {
+			return nil, fmt.Errorf("while rendering template %s: %w", t.String(), fmt.Errorf("element %s at position %d is not a variable or an exact element", te.String(), i))
+		}
+		p[i] = string(e)
+	}
+	return p, nil
+}
@@ -0,0 +1,156 @@
+package dbpath_test
+
+import (
+	"testing"
+
+	"github.com/draganm/bolted/dbpath"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTemplate(t *testing.T) {
+	cases := []struct {
+		title         string
+		input         string
+		canonical     string
+		variables     []string
+		expectedError string
+	}{
+		{
+			title:     "empty",
+			input:     "",
+			canonical: "",
+			variables: []string{},
+		},
+		{
+			title:     "variables and any subpath",
+			input:     "/tenants/:tenant/users/:user/**",
+			canonical: "tenants/:tenant/users/:user/**",
+			variables: []string{"tenant", "user"},
+		},
+		{
+			title:     "matcher elements",
+			input:     "logs/:app/{info,error}/!tmp-*",
+			canonical: "logs/:app/{info,error}/!tmp-*",
+			variables: []string{"app"},
+		},
+		{
+			title:     "escaped colon is a literal",
+			input:     "%3Aliteral/:var",
+			canonical: "%3Aliteral/:var",
+			variables: []string{"var"},
+		},
+		{
+			title:         "any subpath not at the end",
+			input:         "a/**/b",
+			expectedError: "while parsing template part at position 2: \"b\": '**' can only be the last element",
+		},
+		{
+			title:         "duplicate variable",
+			input:         ":a/:a",
+			expectedError: "while parsing template part at position 1: \":a\": duplicate variable \"a\"",
+		},
+		{
+			title:         "empty variable name",
+			input:         "a/:",
+			expectedError: "while parsing template part at position 1: \":\": variable name must not be empty",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			tm, err := dbpath.ParseTemplate(tc.input)
+			if tc.expectedError != "" {
+				require.EqualError(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.canonical, tm.String())
+			require.Equal(t, tc.variables, tm.Variables())
+		})
+	}
+}
+
+func TestTemplateMatch(t *testing.T) {
+	cases := []struct {
+		title          string
+		template       string
+		path           dbpath.Path
+		matches        bool
+		expectedValues map[string]string
+	}{
+		{
+			title:          "binds variables",
+			template:       "tenants/:tenant/users/:user",
+			path:           dbpath.ToPath("tenants", "t1", "users", "u1"),
+			matches:        true,
+			expectedValues: map[string]string{"tenant": "t1", "user": "u1"},
+		},
+		{
+			title:          "binds variables with any subpath",
+			template:       "tenants/:tenant/users/:user/**",
+			path:           dbpath.ToPath("tenants", "t1", "users", "u1", "profile", "email"),
+			matches:        true,
+			expectedValues: map[string]string{"tenant": "t1", "user": "u1"},
+		},
+		{
+			title:          "any subpath matches empty subpath",
+			template:       "tenants/:tenant/**",
+			path:           dbpath.ToPath("tenants", "t1"),
+			matches:        true,
+			expectedValues: map[string]string{"tenant": "t1"},
+		},
+		{
+			title:    "too short path",
+			template: "tenants/:tenant/users/:user",
+			path:     dbpath.ToPath("tenants", "t1", "users"),
+			matches:  false,
+		},
+		{
+			title:    "too long path",
+			template: "tenants/:tenant",
+			path:     dbpath.ToPath("tenants", "t1", "users"),
+			matches:  false,
+		},
+		{
+			title:    "literal mismatch",
+			template: "tenants/:tenant",
+			path:     dbpath.ToPath("users", "t1"),
+			matches:  false,
+		},
+		{
+			title:          "matcher element",
+			template:       "logs/:app/{info,error}",
+			path:           dbpath.ToPath("logs", "web", "error"),
+			matches:        true,
+			expectedValues: map[string]string{"app": "web"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			tm := dbpath.MustParseTemplate(tc.template)
+			values, matches := tm.Match(tc.path)
+			require.Equal(t, tc.matches, matches)
+			require.Equal(t, tc.expectedValues, values)
+			require.Equal(t, tc.matches, tm.Matcher().Matches(tc.path))
+		})
+	}
+}
+
+func TestTemplateRender(t *testing.T) {
+	t.Run("renders variables", func(t *testing.T) {
+		p, err := dbpath.MustParseTemplate("tenants/:tenant/users/:user").Render(map[string]string{"tenant": "t/1", "user": "u1"})
+		require.NoError(t, err)
+		require.Equal(t, dbpath.ToPath("tenants", "t/1", "users", "u1"), p)
+	})
+
+	t.Run("missing variable", func(t *testing.T) {
+		_, err := dbpath.MustParseTemplate("tenants/:tenant/users/:user").Render(map[string]string{"tenant": "t1"})
+		require.EqualError(t, err, "while rendering template tenants/:tenant/users/:user: missing value for variable \"user\"")
+	})
+
+	t.Run("wildcard", func(t *testing.T) {
+		_, err := dbpath.MustParseTemplate("tenants/:tenant/**").Render(map[string]string{"tenant": "t1"})
+		require.EqualError(t, err, "while rendering template tenants/:tenant/**: element ** at position 2 is not a variable or an exact element")
+	})
+}
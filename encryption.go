@@ -0,0 +1,316 @@
+package bolted
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/draganm/bolted/dbpath"
+)
+
+// KeyProvider provides keys for encryption of values.
+// A key with a given ID must never change.
+type KeyProvider interface {
+	// CurrentKeyID returns the ID of the key used to encrypt new values.
+	CurrentKeyID() string
+	// Key returns the AES-128, AES-192 or AES-256 key with the given ID.
+	Key(id string) ([]byte, error)
+}
+
+// KeyRing is a KeyProvider holding keys in memory.
+type KeyRing struct {
+	Current string
+	Keys    map[string][]byte
+}
+
+func (k KeyRing) CurrentKeyID() string {
+	return k.Current
+}
+
+func (k KeyRing) Key(id string) ([]byte, error) {
+	key, found := k.Keys[id]
+	if !found {
+		return nil, fmt.Errorf("key %q not found", id)
+	}
+	return key, nil
+}
+
+var errNoKeyProvider = errors.New("value is encrypted, but no key provider is configured")
+var errCorruptEncryptedValue = errors.New("corrupt encrypted value")
+
+// Encrypted values are stored in an encrypted frame holding the length of the key ID,
+// the key ID, the nonce and the AES-GCM sealed value.
+// The path of the value is used as associated data, so values can't be moved between paths.
+type valueEncryption struct {
+	keys  KeyProvider
+	aeads sync.Map
+}
+
+func newValueEncryption(keys KeyProvider) *valueEncryption {
+	if keys == nil {
+		return nil
+	}
+	return &valueEncryption{keys: keys}
+}
+
+func (e *valueEncryption) aead(keyID string) (cipher.AEAD, error) {
+	a, found := e.aeads.Load(keyID)
+	if found {
+		return a.(cipher.AEAD), nil
+	}
+
+	key, err := e.keys.Key(keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("while creating cipher for key %q: %w", keyID, err)
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	e.aeads.Store(keyID, gcm)
+
+	return gcm, nil
+}
+
+func (e *valueEncryption) encrypt(path dbpath.Path, value []byte) ([]byte, error) {
+	keyID := e.keys.CurrentKeyID()
+	if len(keyID) > 255 {
+		return nil, fmt.Errorf("key id %q is too long", keyID)
+	}
+
+	gcm, err := e.aead(keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	header := []byte(frameMagic)
+	header = append(header, byte(frameEncrypted), byte(len(keyID)))
+	header = append(header, keyID...)
+	header = append(header, nonce...)
+
+	return gcm.Seal(header, nonce, value, []byte(path.String())), nil
+}
+
+func parseEncrypted(payload []byte) (keyID string, sealed []byte, err error) {
+	if len(payload) < 1 || len(payload) < 1+int(payload[0]) {
+		return "", nil, errCorruptEncryptedValue
+	}
+	return string(payload[1 : 1+payload[0]]), payload[1+payload[0]:], nil
+}
+
+func (e *valueEncryption) decrypt(path dbpath.Path, payload []byte) ([]byte, error) {
+	if e == nil {
+		return nil, errNoKeyProvider
+	}
+
+	keyID, sealed, err := parseEncrypted(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := e.aead(keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errCorruptEncryptedValue
+	}
+
+	v, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], []byte(path.String()))
+	if err != nil {
+		return nil, fmt.Errorf("while decrypting value: %w", err)
+	}
+
+	return v, nil
+}
+
+func (w *writeTx) encrypts(path dbpath.Path) bool {
+	return w.encryption != nil && w.encrypted != nil && w.encrypted.Matches(path)
+}
+
+// needsReencryption returns true if the stored value under the path
+// is not encrypted with the current key.
+func (w *writeTx) needsReencryption(path dbpath.Path) (bool, error) {
+	bucket, err := w.bucket(path[:len(path)-1])
+	if err != nil {
+		return false, err
+	}
+
+	v := bucket.Get(w.storedKey(path))
+
+	for {
+		kind, payload, framed := parseFrame(v)
+		if !framed {
+			return true, nil
+		}
+
+		switch kind {
+		case frameContentRef:
+			v, err = w.loadContent(payload)
+			if err != nil {
+				return false, err
+			}
+		case frameEncrypted:
+			keyID, _, err := parseEncrypted(payload)
+			if err != nil {
+				return false, err
+			}
+			return keyID != w.encryption.keys.CurrentKeyID(), nil
+		default:
+			return true, nil
+		}
+	}
+}
+
+// reencrypt rewrites the value stored under the path encrypted with the current key.
+// The logical value doesn't change, so no change is recorded for observers.
+func (w *writeTx) reencrypt(path dbpath.Path) error {
+	bucket, err := w.bucket(path[:len(path)-1])
+	if err != nil {
+		return err
+	}
+
+	key := w.storedKey(path)
+
+	old := bucket.Get(key)
+
+	var value []byte
+	if old == nil {
+		// blobs stored before encryption was enabled are replaced by encrypted values
+		blob := bucket.Bucket(key)
+		if !isBlob(blob) {
+			return errors.New("value not found")
+		}
+		value, err = readBlob(blob)
+		if err != nil {
+			return err
+		}
+	} else {
+		value, err = w.decodeValue(path, old)
+		if err != nil {
+			return err
+		}
+	}
+
+	value, err = w.encodeValue(path, value)
+	if err != nil {
+		return err
+	}
+
+	if old == nil {
+		_, err = deleteBlob(bucket, key)
+	} else {
+		err = w.releaseValue(old)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	bucket.SetFillPercent(w.fillPercent)
+
+	err = bucket.Put(key, value)
+	if err != nil {
+		return err
+	}
+
+	w.rewritten = append(w.rewritten, path)
+
+	return nil
+}
+
+// ReencryptValues encrypts all values matched by Options.Encrypted that are not encrypted
+// with the current key of the key provider. Values are re-encrypted in write transactions
+// of at most batchSize values, so it can run in the background while the database is in use.
+// It returns the number of re-encrypted values.
+func (b *LocalDB) ReencryptValues(ctx context.Context, batchSize int) (reencrypted int, err error) {
+	if b.encryption == nil || b.options.Encrypted == nil {
+		return 0, errors.New("value encryption is not configured")
+	}
+
+	if batchSize < 1 {
+		return 0, errors.New("batch size must be positive")
+	}
+
+	stale := []dbpath.Path{}
+
+	err = b.ReadWithContext(ctx, func(tx ReadTx) error {
+		w := tx.(*writeTx)
+		return w.FindWithFunc(b.options.Encrypted, func(path dbpath.Path, isMap bool) error {
+			if isMap {
+				return nil
+			}
+			needed, err := w.needsReencryption(path)
+			if err != nil {
+				return fmt.Errorf("while checking %s: %w", path, err)
+			}
+			if needed {
+				stale = append(stale, path)
+			}
+			return nil
+		})
+	})
+
+	if err != nil {
+		return 0, fmt.Errorf("while finding values to re-encrypt: %w", err)
+	}
+
+	for len(stale) > 0 {
+		batch := stale
+		if len(batch) > batchSize {
+			batch = batch[:batchSize]
+		}
+		stale = stale[len(batch):]
+
+		inBatch := 0
+
+		err = b.WriteWithContext(ctx, func(tx WriteTx) error {
+			w := tx.(*writeTx)
+			for _, path := range batch {
+				// values could have been changed or deleted in the meantime
+				if !w.Exists(path) || w.IsMap(path) {
+					continue
+				}
+				needed, err := w.needsReencryption(path)
+				if err != nil {
+					return fmt.Errorf("while checking %s: %w", path, err)
+				}
+				if !needed {
+					continue
+				}
+				err = w.reencrypt(path)
+				if err != nil {
+					return fmt.Errorf("while re-encrypting %s: %w", path, err)
+				}
+				inBatch++
+			}
+			return nil
+		})
+
+		if err != nil {
+			return reencrypted, fmt.Errorf("while re-encrypting values: %w", err)
+		}
+
+		reencrypted += inBatch
+	}
+
+	return reencrypted, nil
+}
@@ -0,0 +1,196 @@
+package bolted_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/draganm/bolted"
+	"github.com/draganm/bolted/dbpath"
+	"github.com/stretchr/testify/require"
+	"go.etcd.io/bbolt"
+)
+
+// storedValue returns the value as stored in the bolt database file.
+func storedValue(t *testing.T, dbFile string, path dbpath.Path) []byte {
+	db, err := bbolt.Open(dbFile, 0660, nil)
+	require.NoError(t, err)
+	defer db.Close()
+
+	var v []byte
+	err = db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("root"))
+		for _, e := range path[:len(path)-1] {
+			b = b.Bucket([]byte(e))
+		}
+		v = append(v, b.Get([]byte(path[len(path)-1]))...)
+		return nil
+	})
+	require.NoError(t, err)
+	return v
+}
+
+func TestEncryptedValues(t *testing.T) {
+	td, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(td)
+
+	dbFile := filepath.Join(td, "db")
+
+	key1 := bytes.Repeat([]byte{1}, 32)
+	key2 := bytes.Repeat([]byte{2}, 32)
+
+	secrets := dbpath.ToPath("secrets")
+	plain := secrets.Append("plain")
+	secret := secrets.Append("secret")
+	streamed := secrets.Append("streamed")
+	moved := secrets.Append("moved")
+	marker := secrets.Append("marker")
+
+	plainValue := []byte("stored before encryption was enabled")
+	secretValue := []byte("customer data")
+
+	open := func(t *testing.T, keys bolted.KeyProvider) *bolted.LocalDB {
+		bdb, err := bolted.Open(dbFile, 0660, bolted.Options{
+			Encrypted:   dbpath.MustParseMatcher("secrets/*"),
+			KeyProvider: keys,
+		})
+		require.NoError(t, err)
+		return bdb
+	}
+
+	t.Run("values stored before encryption was enabled", func(t *testing.T) {
+		bdb, err := bolted.Open(dbFile, 0660, bolted.Options{})
+		require.NoError(t, err)
+		defer bdb.Close()
+
+		err = bdb.Write(func(tx bolted.WriteTx) error {
+			tx.CreateMap(secrets)
+			tx.Put(plain, plainValue)
+			return nil
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("values are encrypted on put", func(t *testing.T) {
+		bdb := open(t, bolted.KeyRing{Current: "k1", Keys: map[string][]byte{"k1": key1}})
+		defer bdb.Close()
+
+		err := bdb.Write(func(tx bolted.WriteTx) error {
+			tx.Put(secret, secretValue)
+			tx.PutReader(streamed, bytes.NewReader(secretValue))
+			return nil
+		})
+		require.NoError(t, err)
+
+		err = bdb.Read(func(tx bolted.ReadTx) error {
+			require.Equal(t, plainValue, tx.Get(plain))
+			require.Equal(t, secretValue, tx.Get(secret))
+			require.Equal(t, secretValue, tx.Get(streamed))
+			require.Equal(t, uint64(len(secretValue)), tx.GetSizeOf(secret))
+
+			it := tx.Iterate(secrets)
+			it.Seek("secret")
+			require.Equal(t, secretValue, it.GetValue())
+			require.Equal(t, uint64(len(secretValue)), it.GetSize())
+			return nil
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("plaintext is not stored", func(t *testing.T) {
+		require.False(t, bytes.Contains(storedValue(t, dbFile, secret), secretValue))
+		require.False(t, bytes.Contains(storedValue(t, dbFile, streamed), secretValue))
+	})
+
+	t.Run("encrypted values can't be read without the key", func(t *testing.T) {
+		bdb, err := bolted.Open(dbFile, 0660, bolted.Options{})
+		require.NoError(t, err)
+		defer bdb.Close()
+
+		err = bdb.Read(func(tx bolted.ReadTx) error {
+			tx.Get(secret)
+			return nil
+		})
+		require.Error(t, err)
+	})
+
+	t.Run("encrypted values are bound to their path", func(t *testing.T) {
+		ciphertext := storedValue(t, dbFile, secret)
+
+		db, err := bbolt.Open(dbFile, 0660, nil)
+		require.NoError(t, err)
+		err = db.Update(func(tx *bbolt.Tx) error {
+			b := tx.Bucket([]byte("root")).Bucket([]byte("secrets"))
+			err := b.Put([]byte("moved"), ciphertext)
+			if err != nil {
+				return err
+			}
+			b.NextSequence()
+			return nil
+		})
+		require.NoError(t, err)
+		require.NoError(t, db.Close())
+
+		bdb := open(t, bolted.KeyRing{Current: "k1", Keys: map[string][]byte{"k1": key1}})
+		defer bdb.Close()
+
+		err = bdb.Read(func(tx bolted.ReadTx) error {
+			tx.Get(moved)
+			return nil
+		})
+		require.Error(t, err)
+
+		err = bdb.Write(func(tx bolted.WriteTx) error {
+			tx.Delete(moved)
+			return nil
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("rotating to a new key", func(t *testing.T) {
+		bdb := open(t, bolted.KeyRing{Current: "k2", Keys: map[string][]byte{"k1": key1, "k2": key2}})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		updates := bdb.Observe(ctx, secrets.ToMatcher().AppendAnySubpathMatcher())
+		<-updates
+
+		n, err := bdb.ReencryptValues(context.Background(), 2)
+		require.NoError(t, err)
+		require.Equal(t, 3, n)
+
+		n, err = bdb.ReencryptValues(context.Background(), 2)
+		require.NoError(t, err)
+		require.Equal(t, 0, n)
+
+		err = bdb.Write(func(tx bolted.WriteTx) error {
+			tx.Put(marker, nil)
+			return nil
+		})
+		require.NoError(t, err)
+
+		require.Equal(t, bolted.ObservedChanges{
+			{Path: marker, Type: bolted.ChangeTypeValueSet},
+		}, <-updates, "re-encryption must not be observed")
+
+		require.NoError(t, bdb.Close())
+
+		bdb = open(t, bolted.KeyRing{Current: "k2", Keys: map[string][]byte{"k2": key2}})
+
+		err = bdb.Read(func(tx bolted.ReadTx) error {
+			require.Equal(t, plainValue, tx.Get(plain))
+			require.Equal(t, secretValue, tx.Get(secret))
+			require.Equal(t, secretValue, tx.Get(streamed))
+			return nil
+		})
+		require.NoError(t, err)
+		require.NoError(t, bdb.Close())
+
+		require.False(t, bytes.Contains(storedValue(t, dbFile, plain), plainValue))
+	})
+}
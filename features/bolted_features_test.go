@@ -15,23 +15,42 @@ import (
 )
 
 func TestFeatures(t *testing.T) {
-	testrunner.RunScenarios(t, steps)
+	t.Run("local", func(t *testing.T) {
+		openDatabase = openLocalDatabase
+		testrunner.RunScenarios(t, steps)
+	})
+
+	t.Run("memory", func(t *testing.T) {
+		openDatabase = openMemoryDatabase
+		testrunner.RunScenarios(t, steps)
+	})
 }
 
 var steps = step.NewRegistry()
 var ctx = context.Background()
 
-var _ = steps.Then("the database is open", func(w *world.World) error {
+// openDatabase opens the database implementation the scenarios are run against.
+var openDatabase func(w *world.World) (bolted.Database, error)
+
+func openLocalDatabase(w *world.World) (bolted.Database, error) {
 	td, err := os.MkdirTemp("", "*")
 	if err != nil {
-		return fmt.Errorf("while creating temp dir: %w", err)
+		return nil, fmt.Errorf("while creating temp dir: %w", err)
 	}
 
 	w.AddCleanup(func() error {
 		return os.RemoveAll(td)
 	})
 
-	db, err := bolted.Open(filepath.Join(td, "db"), 0700, bolted.Options{})
+	return bolted.Open(filepath.Join(td, "db"), 0700, bolted.Options{})
+}
+
+func openMemoryDatabase(w *world.World) (bolted.Database, error) {
+	return bolted.OpenMemory(bolted.Options{})
+}
+
+var _ = steps.Then("the database is open", func(w *world.World) error {
+	db, err := openDatabase(w)
 	if err != nil {
 		return err
 	}
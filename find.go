@@ -0,0 +1,100 @@
+package bolted
+
+import (
+	"github.com/draganm/bolted/dbpath"
+)
+
+// FindFunc is called for every existing path matching the matcher passed to FindWithFunc.
+type FindFunc func(path dbpath.Path, isMap bool) error
+
+// Find returns all existing paths matching the matcher.
+func (w *writeTx) Find(m dbpath.Matcher) []dbpath.Path {
+	w.checkForCancelledContext()
+
+	root, err := w.bucket(dbpath.NilPath)
+	if err != nil {
+		raiseErrorForPath(dbpath.NilPath, "Find", err)
+	}
+
+	found := []dbpath.Path{}
+	err = w.find(root, dbpath.Path{}, m.Start(), func(path dbpath.Path, isMap bool) error {
+		found = append(found, path)
+		return nil
+	})
+	if err != nil {
+		raiseErrorForPath(dbpath.NilPath, "Find", err)
+	}
+
+	return found
+}
+
+// FindWithFunc streams all existing paths matching the matcher to fn.
+// Subtrees that can't contain a matching path are not traversed,
+// and exact matcher elements are looked up directly without scanning siblings.
+func (w *writeTx) FindWithFunc(m dbpath.Matcher, fn FindFunc) error {
+	w.checkForCancelledContext()
+
+	root, err := w.bucket(dbpath.NilPath)
+	if err != nil {
+		raiseErrorForPath(dbpath.NilPath, "FindWithFunc", err)
+	}
+
+	return w.find(root, dbpath.Path{}, m.Start(), fn)
+}
+
+func (w *writeTx) find(bucket storageBucket, path dbpath.Path, state dbpath.MatchState, fn FindFunc) error {
+	w.checkForCancelledContext()
+
+	if state.Matches() {
+		err := fn(path, true)
+		if err != nil {
+			return err
+		}
+	}
+
+	visit := func(k, v []byte, childPath dbpath.Path, childState dbpath.MatchState) error {
+		var child storageBucket
+		if v == nil {
+			child = bucket.Bucket(k)
+		}
+		if v != nil || isBlob(child) {
+			if childState.Matches() {
+				return fn(childPath, false)
+			}
+			return nil
+		}
+		return w.find(child, childPath, childState, fn)
+	}
+
+	candidates, exact := state.ExactCandidates()
+	if exact {
+		for _, c := range candidates {
+			childPath := path.Append(c)
+			k := w.storedKey(childPath)
+			v := bucket.Get(k)
+			if v == nil && bucket.Bucket(k) == nil {
+				continue
+			}
+			err := visit(k, v, childPath, state.Step(c))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	c := bucket.Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		childPath := w.childPath(path, k)
+		childState := state.Step(childPath[len(childPath)-1])
+		if childState.IsDead() && !childState.Matches() {
+			continue
+		}
+		err := visit(k, v, childPath, childState)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
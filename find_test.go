@@ -0,0 +1,100 @@
+package bolted_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/draganm/bolted"
+	"github.com/draganm/bolted/dbpath"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFind(t *testing.T) {
+	bdb, cleanup := openEmptyDatabase(t, bolted.Options{})
+	defer cleanup()
+
+	err := bdb.Write(func(tx bolted.WriteTx) error {
+		tx.CreateMap(dbpath.ToPath("tenants"))
+		for _, tenant := range []string{"t1", "t2"} {
+			tx.CreateMap(dbpath.ToPath("tenants", tenant))
+			tx.CreateMap(dbpath.ToPath("tenants", tenant, "users"))
+			for _, user := range []string{"u1", "u2"} {
+				tx.CreateMap(dbpath.ToPath("tenants", tenant, "users", user))
+				tx.Put(dbpath.ToPath("tenants", tenant, "users", user, "email"), []byte(user+"@"+tenant))
+			}
+		}
+		tx.CreateMap(dbpath.ToPath("tenants", "t2", "users", "u3"))
+		tx.Put(dbpath.ToPath("email"), []byte("root"))
+		return nil
+	})
+	require.NoError(t, err)
+
+	find := func(m string) (found []string) {
+		found = []string{}
+		err := bdb.Read(func(tx bolted.ReadTx) error {
+			for _, p := range tx.Find(dbpath.MustParseMatcher(m)) {
+				found = append(found, p.String())
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		return found
+	}
+
+	cases := []struct {
+		matcher  string
+		expected []string
+	}{
+		{
+			matcher:  "",
+			expected: []string{""},
+		},
+		{
+			matcher:  "tenants/*/users/*/email",
+			expected: []string{"tenants/t1/users/u1/email", "tenants/t1/users/u2/email", "tenants/t2/users/u1/email", "tenants/t2/users/u2/email"},
+		},
+		{
+			matcher:  "tenants/t2/users/*",
+			expected: []string{"tenants/t2/users/u1", "tenants/t2/users/u2", "tenants/t2/users/u3"},
+		},
+		{
+			matcher:  "tenants/{t1,missing}/users/u2/email",
+			expected: []string{"tenants/t1/users/u2/email"},
+		},
+		{
+			matcher:  "**/email",
+			expected: []string{"email", "tenants/t1/users/u1/email", "tenants/t1/users/u2/email", "tenants/t2/users/u1/email", "tenants/t2/users/u2/email"},
+		},
+		{
+			matcher:  "tenants/!t1/users/*/email",
+			expected: []string{"tenants/t2/users/u1/email", "tenants/t2/users/u2/email"},
+		},
+		{
+			matcher:  "email/*",
+			expected: []string{},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.matcher, func(t *testing.T) {
+			require.Equal(t, tc.expected, find(tc.matcher))
+		})
+	}
+
+	t.Run("streaming stops on error", func(t *testing.T) {
+		stop := errors.New("stop")
+		found := []dbpath.Path{}
+		err := bdb.Read(func(tx bolted.ReadTx) error {
+			return tx.FindWithFunc(dbpath.MustParseMatcher("**/email"), func(path dbpath.Path, isMap bool) error {
+				require.False(t, isMap)
+				found = append(found, path)
+				if len(found) == 2 {
+					return stop
+				}
+				return nil
+			})
+		})
+		require.ErrorIs(t, err, stop)
+		require.Len(t, found, 2)
+	})
+}
@@ -1,6 +1,6 @@
 module github.com/draganm/bolted
 
-go 1.18
+go 1.23
 
 require (
 	github.com/draganm/senfgurke v0.1.1
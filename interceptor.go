@@ -0,0 +1,417 @@
+package bolted
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/draganm/bolted/dbpath"
+	"go.etcd.io/bbolt"
+)
+
+// TransactionInterceptor is called for every transaction of a Database wrapped by WithInterceptors.
+// It must call invoke to run the transaction, and can replace the context passed to it.
+type TransactionInterceptor func(ctx context.Context, write bool, invoke func(ctx context.Context) error) error
+
+// Operation describes a call of a transaction method seen by an OperationInterceptor.
+type Operation struct {
+	// Method is the name of the called method, e.g. "Put".
+	Method string
+	// Path is the path passed to the method. Methods operating on many keys pass the path of their parent map.
+	Path dbpath.Path
+	// Write is true for methods modifying the database.
+	Write bool
+	// ValueSize is the size of the written values, or of the read values once invoke returned.
+	ValueSize int
+	// Duration is the time the method took to run, set once invoke returned.
+	Duration time.Duration
+	// Err is the error returned or raised by the method, set once invoke returned.
+	// Errors returned by interceptors without calling invoke are not included.
+	Err error
+}
+
+// OperationInterceptor is called for every call of a transaction method, except ID and Context,
+// of a Database wrapped by WithInterceptors. It must call invoke to call the method.
+// Errors returned by the interceptor are raised as errors of the method.
+type OperationInterceptor func(ctx context.Context, op *Operation, invoke func() error) error
+
+// Interceptor intercepts transactions and their operations. Both fields are optional.
+type Interceptor struct {
+	Transaction TransactionInterceptor
+	Operation   OperationInterceptor
+}
+
+// WithInterceptors returns a Database calling interceptors for all transactions and their operations.
+// The first interceptor is the outermost one.
+func WithInterceptors(db Database, interceptors ...Interceptor) Database {
+	txInterceptor := func(ctx context.Context, write bool, invoke func(ctx context.Context) error) error {
+		return invoke(ctx)
+	}
+
+	opInterceptor := func(ctx context.Context, op *Operation, invoke func() error) error {
+		return invoke()
+	}
+
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		ti := interceptors[i].Transaction
+		if ti != nil {
+			next := txInterceptor
+			txInterceptor = func(ctx context.Context, write bool, invoke func(ctx context.Context) error) error {
+				return ti(ctx, write, func(ctx context.Context) error {
+					return next(ctx, write, invoke)
+				})
+			}
+		}
+
+		oi := interceptors[i].Operation
+		if oi != nil {
+			next := opInterceptor
+			opInterceptor = func(ctx context.Context, op *Operation, invoke func() error) error {
+				return oi(ctx, op, func() error {
+					return next(ctx, op, invoke)
+				})
+			}
+		}
+	}
+
+	return &interceptedDB{
+		db:            db,
+		txInterceptor: txInterceptor,
+		opInterceptor: opInterceptor,
+	}
+}
+
+type interceptedDB struct {
+	db            Database
+	txInterceptor TransactionInterceptor
+	opInterceptor OperationInterceptor
+}
+
+func (d *interceptedDB) Read(fn func(tx ReadTx) error) error {
+	return d.ReadWithContext(context.Background(), fn)
+}
+
+func (d *interceptedDB) ReadWithContext(ctx context.Context, fn func(tx ReadTx) error) error {
+	return d.txInterceptor(ctx, false, func(ctx context.Context) error {
+		return d.db.ReadWithContext(ctx, func(tx ReadTx) error {
+			return fn(&interceptedTx{ReadTx: tx, wtx: notWritableTx{tx}, interceptor: d.opInterceptor})
+		})
+	})
+}
+
+func (d *interceptedDB) Write(fn func(tx WriteTx) error) error {
+	return d.WriteWithContext(context.Background(), fn)
+}
+
+func (d *interceptedDB) WriteWithContext(ctx context.Context, fn func(tx WriteTx) error) error {
+	return d.txInterceptor(ctx, true, func(ctx context.Context) error {
+		return d.db.WriteWithContext(ctx, func(tx WriteTx) error {
+			return fn(&interceptedTx{ReadTx: tx, wtx: tx, interceptor: d.opInterceptor})
+		})
+	})
+}
+
+func (d *interceptedDB) Observe(ctx context.Context, path dbpath.Matcher) <-chan ObservedChanges {
+	return d.db.Observe(ctx, path)
+}
+
+func (d *interceptedDB) Close() error {
+	return d.db.Close()
+}
+
+func (d *interceptedDB) Stats() (*bbolt.Stats, error) {
+	return d.db.Stats()
+}
+
+// notWritableTx is passed as the write transaction to wrappers of read transactions.
+// Writes raise bbolt.ErrTxNotWritable, like writes to read transactions of LocalDB do.
+type notWritableTx struct {
+	ReadTx
+}
+
+func (notWritableTx) CreateMap(path dbpath.Path) {
+	raiseErrorForPath(path, "CreateMap", bbolt.ErrTxNotWritable)
+}
+
+func (notWritableTx) Delete(path dbpath.Path) {
+	raiseErrorForPath(path, "Delete", bbolt.ErrTxNotWritable)
+}
+
+func (notWritableTx) Put(path dbpath.Path, value []byte) {
+	raiseErrorForPath(path, "Put", bbolt.ErrTxNotWritable)
+}
+
+func (notWritableTx) PutMany(parent dbpath.Path, kvs []KeyValue) {
+	raiseErrorForPath(parent, "PutMany", bbolt.ErrTxNotWritable)
+}
+
+func (notWritableTx) PutReader(path dbpath.Path, r io.Reader) {
+	raiseErrorForPath(path, "PutReader", bbolt.ErrTxNotWritable)
+}
+
+func (notWritableTx) DeleteMany(parent dbpath.Path, keys []string) {
+	raiseErrorForPath(parent, "DeleteMany", bbolt.ErrTxNotWritable)
+}
+
+// SetFillPercent is ignored, as it is by read transactions of LocalDB.
+func (notWritableTx) SetFillPercent(fillPercent float64) {}
+
+// interceptedTx calls the interceptor for every method of the wrapped transaction.
+// The embedded ReadTx provides ID and Context, all other methods are intercepted.
+type interceptedTx struct {
+	ReadTx
+	// wtx is notWritableTx for read transactions
+	wtx         WriteTx
+	interceptor OperationInterceptor
+}
+
+// intercept calls fn through the interceptor and returns its error, including raised errors.
+func (t *interceptedTx) intercept(op *Operation, fn func() error) error {
+	return t.interceptor(t.Context(), op, func() error {
+		start := time.Now()
+		err := runTx(fn)
+		op.Duration = time.Since(start)
+		op.Err = err
+		return err
+	})
+}
+
+// call calls fn through the interceptor and raises the error, like all transaction methods do.
+func (t *interceptedTx) call(op *Operation, fn func()) {
+	err := t.intercept(op, func() error {
+		fn()
+		return nil
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (t *interceptedTx) CreateMap(path dbpath.Path) {
+	t.call(&Operation{Method: "CreateMap", Path: path, Write: true}, func() {
+		t.wtx.CreateMap(path)
+	})
+}
+
+func (t *interceptedTx) Delete(path dbpath.Path) {
+	t.call(&Operation{Method: "Delete", Path: path, Write: true}, func() {
+		t.wtx.Delete(path)
+	})
+}
+
+func (t *interceptedTx) Put(path dbpath.Path, value []byte) {
+	t.call(&Operation{Method: "Put", Path: path, Write: true, ValueSize: len(value)}, func() {
+		t.wtx.Put(path, value)
+	})
+}
+
+func (t *interceptedTx) PutMany(parent dbpath.Path, kvs []KeyValue) {
+	size := 0
+	for _, kv := range kvs {
+		size += len(kv.Value)
+	}
+	t.call(&Operation{Method: "PutMany", Path: parent, Write: true, ValueSize: size}, func() {
+		t.wtx.PutMany(parent, kvs)
+	})
+}
+
+func (t *interceptedTx) PutReader(path dbpath.Path, r io.Reader) {
+	op := &Operation{Method: "PutReader", Path: path, Write: true}
+	t.call(op, func() {
+		cr := &countingReader{r: r}
+		t.wtx.PutReader(path, cr)
+		op.ValueSize = cr.n
+	})
+}
+
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += n
+	return n, err
+}
+
+func (t *interceptedTx) DeleteMany(parent dbpath.Path, keys []string) {
+	t.call(&Operation{Method: "DeleteMany", Path: parent, Write: true}, func() {
+		t.wtx.DeleteMany(parent, keys)
+	})
+}
+
+func (t *interceptedTx) SetFillPercent(fillPercent float64) {
+	t.call(&Operation{Method: "SetFillPercent", Path: dbpath.NilPath}, func() {
+		t.wtx.SetFillPercent(fillPercent)
+	})
+}
+
+func (t *interceptedTx) Get(path dbpath.Path) (v []byte) {
+	op := &Operation{Method: "Get", Path: path}
+	t.call(op, func() {
+		v = t.ReadTx.Get(path)
+		op.ValueSize = len(v)
+	})
+	return v
+}
+
+func (t *interceptedTx) View(path dbpath.Path, fn func(v []byte) error) error {
+	op := &Operation{Method: "View", Path: path}
+	return t.intercept(op, func() error {
+		return t.ReadTx.View(path, func(v []byte) error {
+			op.ValueSize = len(v)
+			return fn(v)
+		})
+	})
+}
+
+func (t *interceptedTx) GetInto(path dbpath.Path, buf []byte) (v []byte) {
+	op := &Operation{Method: "GetInto", Path: path}
+	t.call(op, func() {
+		v = t.ReadTx.GetInto(path, buf)
+		op.ValueSize = len(v)
+	})
+	return v
+}
+
+func (t *interceptedTx) GetMany(parent dbpath.Path, keys []string) (values [][]byte) {
+	op := &Operation{Method: "GetMany", Path: parent}
+	t.call(op, func() {
+		values = t.ReadTx.GetMany(parent, keys)
+		for _, v := range values {
+			op.ValueSize += len(v)
+		}
+	})
+	return values
+}
+
+func (t *interceptedTx) OpenReader(path dbpath.Path) (r io.ReadSeeker) {
+	t.call(&Operation{Method: "OpenReader", Path: path}, func() {
+		r = t.ReadTx.OpenReader(path)
+	})
+	return r
+}
+
+func (t *interceptedTx) Iterate(path dbpath.Path) (it Iterator) {
+	t.call(&Operation{Method: "Iterate", Path: path}, func() {
+		it = t.ReadTx.Iterate(path)
+	})
+	return it
+}
+
+func (t *interceptedTx) IterateRange(path dbpath.Path, from, to Bound) (it Iterator) {
+	t.call(&Operation{Method: "IterateRange", Path: path}, func() {
+		it = t.ReadTx.IterateRange(path, from, to)
+	})
+	return it
+}
+
+func (t *interceptedTx) IterateRangeReverse(path dbpath.Path, from, to Bound) (it Iterator) {
+	t.call(&Operation{Method: "IterateRangeReverse", Path: path}, func() {
+		it = t.ReadTx.IterateRangeReverse(path, from, to)
+	})
+	return it
+}
+
+func (t *interceptedTx) IteratePrefix(path dbpath.Path, prefix string) (it Iterator) {
+	t.call(&Operation{Method: "IteratePrefix", Path: path}, func() {
+		it = t.ReadTx.IteratePrefix(path, prefix)
+	})
+	return it
+}
+
+func (t *interceptedTx) IteratePrefixReverse(path dbpath.Path, prefix string) (it Iterator) {
+	t.call(&Operation{Method: "IteratePrefixReverse", Path: path}, func() {
+		it = t.ReadTx.IteratePrefixReverse(path, prefix)
+	})
+	return it
+}
+
+func pageValueSize(res PageResult) int {
+	size := 0
+	for _, e := range res.Entries {
+		size += len(e.Value)
+	}
+	return size
+}
+
+func (t *interceptedTx) Page(path dbpath.Path, token string, limit int) (res PageResult) {
+	op := &Operation{Method: "Page", Path: path}
+	t.call(op, func() {
+		res = t.ReadTx.Page(path, token, limit)
+		op.ValueSize = pageValueSize(res)
+	})
+	return res
+}
+
+func (t *interceptedTx) PageReverse(path dbpath.Path, token string, limit int) (res PageResult) {
+	op := &Operation{Method: "PageReverse", Path: path}
+	t.call(op, func() {
+		res = t.ReadTx.PageReverse(path, token, limit)
+		op.ValueSize = pageValueSize(res)
+	})
+	return res
+}
+
+func (t *interceptedTx) Walk(root dbpath.Path, fn WalkFunc) error {
+	return t.intercept(&Operation{Method: "Walk", Path: root}, func() error {
+		return t.ReadTx.Walk(root, fn)
+	})
+}
+
+func (t *interceptedTx) WalkWithOptions(root dbpath.Path, opts WalkOptions, fn WalkFunc) error {
+	return t.intercept(&Operation{Method: "WalkWithOptions", Path: root}, func() error {
+		return t.ReadTx.WalkWithOptions(root, opts, fn)
+	})
+}
+
+func (t *interceptedTx) Find(m dbpath.Matcher) (found []dbpath.Path) {
+	t.call(&Operation{Method: "Find", Path: dbpath.NilPath}, func() {
+		found = t.ReadTx.Find(m)
+	})
+	return found
+}
+
+func (t *interceptedTx) FindWithFunc(m dbpath.Matcher, fn FindFunc) error {
+	return t.intercept(&Operation{Method: "FindWithFunc", Path: dbpath.NilPath}, func() error {
+		return t.ReadTx.FindWithFunc(m, fn)
+	})
+}
+
+func (t *interceptedTx) Exists(path dbpath.Path) (ex bool) {
+	t.call(&Operation{Method: "Exists", Path: path}, func() {
+		ex = t.ReadTx.Exists(path)
+	})
+	return ex
+}
+
+func (t *interceptedTx) IsMap(path dbpath.Path) (ism bool) {
+	t.call(&Operation{Method: "IsMap", Path: path}, func() {
+		ism = t.ReadTx.IsMap(path)
+	})
+	return ism
+}
+
+func (t *interceptedTx) GetSizeOf(path dbpath.Path) (s uint64) {
+	t.call(&Operation{Method: "GetSizeOf", Path: path}, func() {
+		s = t.ReadTx.GetSizeOf(path)
+	})
+	return s
+}
+
+func (t *interceptedTx) DumpDatabase(w io.Writer) (n int64) {
+	op := &Operation{Method: "DumpDatabase", Path: dbpath.NilPath}
+	t.call(op, func() {
+		n = t.ReadTx.DumpDatabase(w)
+		op.ValueSize = int(n)
+	})
+	return n
+}
+
+func (t *interceptedTx) GetDBFileSize() (s int64) {
+	t.call(&Operation{Method: "GetDBFileSize", Path: dbpath.NilPath}, func() {
+		s = t.ReadTx.GetDBFileSize()
+	})
+	return s
+}
@@ -0,0 +1,177 @@
+package bolted_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/draganm/bolted"
+	"github.com/draganm/bolted/dbpath"
+	"github.com/stretchr/testify/require"
+	"go.etcd.io/bbolt"
+)
+
+type recordedOperation struct {
+	Method    string
+	Path      string
+	Write     bool
+	ValueSize int
+	Err       error
+}
+
+type contextKey string
+
+func TestInterceptors(t *testing.T) {
+	db, cleanup := openEmptyDatabase(t, bolted.Options{})
+	defer cleanup()
+
+	calls := []string{}
+	operations := []recordedOperation{}
+	injected := errors.New("injected failure")
+
+	idb := bolted.WithInterceptors(
+		db,
+		bolted.Interceptor{
+			Transaction: func(ctx context.Context, write bool, invoke func(ctx context.Context) error) error {
+				calls = append(calls, "outer")
+				return invoke(context.WithValue(ctx, contextKey("principal"), "alice"))
+			},
+			Operation: func(ctx context.Context, op *bolted.Operation, invoke func() error) error {
+				err := invoke()
+				require.Equal(t, "alice", ctx.Value(contextKey("principal")))
+				operations = append(operations, recordedOperation{
+					Method:    op.Method,
+					Path:      op.Path.String(),
+					Write:     op.Write,
+					ValueSize: op.ValueSize,
+					Err:       op.Err,
+				})
+				return err
+			},
+		},
+		bolted.Interceptor{
+			Transaction: func(ctx context.Context, write bool, invoke func(ctx context.Context) error) error {
+				calls = append(calls, "inner")
+				return invoke(ctx)
+			},
+			Operation: func(ctx context.Context, op *bolted.Operation, invoke func() error) error {
+				if op.Path.String() == "fail" {
+					return injected
+				}
+				return invoke()
+			},
+		},
+	)
+
+	t.Run("operations are intercepted", func(t *testing.T) {
+		operations = nil
+		calls = nil
+
+		err := idb.Write(func(tx bolted.WriteTx) error {
+			tx.CreateMap(dbpath.ToPath("m"))
+			tx.Put(dbpath.ToPath("m", "v"), []byte("value"))
+			require.Equal(t, []byte("value"), tx.Get(dbpath.ToPath("m", "v")))
+			require.Equal(t, "alice", tx.Context().Value(contextKey("principal")))
+			return nil
+		})
+		require.NoError(t, err)
+
+		require.Equal(t, []string{"outer", "inner"}, calls)
+		require.Equal(t, []recordedOperation{
+			{Method: "CreateMap", Path: "m", Write: true},
+			{Method: "Put", Path: "m/v", Write: true, ValueSize: 5},
+			{Method: "Get", Path: "m/v", ValueSize: 5},
+		}, operations)
+	})
+
+	t.Run("errors are seen by interceptors", func(t *testing.T) {
+		operations = nil
+
+		err := idb.Read(func(tx bolted.ReadTx) error {
+			tx.Get(dbpath.ToPath("m", "missing"))
+			return nil
+		})
+		require.Error(t, err)
+
+		require.Len(t, operations, 1)
+		require.Equal(t, "Get", operations[0].Method)
+		require.Equal(t, err, operations[0].Err)
+	})
+
+	t.Run("durations are reported to interceptors", func(t *testing.T) {
+		var duration time.Duration
+		tdb := bolted.WithInterceptors(db, bolted.Interceptor{
+			Operation: func(ctx context.Context, op *bolted.Operation, invoke func() error) error {
+				err := invoke()
+				duration = op.Duration
+				return err
+			},
+		})
+
+		err := tdb.Read(func(tx bolted.ReadTx) error {
+			return tx.Walk(dbpath.ToPath("m"), func(dbpath.Path, bool, []byte) error {
+				time.Sleep(time.Millisecond)
+				return nil
+			})
+		})
+		require.NoError(t, err)
+		require.GreaterOrEqual(t, duration, time.Millisecond)
+	})
+
+	t.Run("injected failures abort the transaction", func(t *testing.T) {
+		err := idb.Write(func(tx bolted.WriteTx) error {
+			tx.Put(dbpath.ToPath("m", "w"), []byte("value"))
+			tx.Put(dbpath.ToPath("fail"), []byte("value"))
+			return nil
+		})
+		require.Equal(t, injected, err)
+
+		err = db.Read(func(tx bolted.ReadTx) error {
+			require.False(t, tx.Exists(dbpath.ToPath("m", "w")))
+			require.False(t, tx.Exists(dbpath.ToPath("fail")))
+			return nil
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("methods returning errors return intercepted errors", func(t *testing.T) {
+		err := idb.Read(func(tx bolted.ReadTx) error {
+			return tx.Walk(dbpath.ToPath("fail"), func(dbpath.Path, bool, []byte) error {
+				return nil
+			})
+		})
+		require.Equal(t, injected, err)
+	})
+}
+
+func TestWrappedReadTransactionsAreNotWritable(t *testing.T) {
+	db, cleanup := openEmptyDatabase(t, bolted.Options{})
+	defer cleanup()
+
+	cases := []struct {
+		name string
+		db   bolted.Database
+	}{
+		{name: "interceptors", db: bolted.WithInterceptors(db, bolted.Interceptor{})},
+		{name: "sub", db: db.Sub(dbpath.NilPath)},
+		{
+			name: "acl",
+			db: bolted.WithACL(db, bolted.ACL{
+				Rules: []bolted.ACLRule{
+					{Principal: bolted.AnyPrincipal, Matcher: dbpath.MustParseMatcher("**"), Permissions: bolted.PermissionAll},
+				},
+			}),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := c.db.Read(func(tx bolted.ReadTx) error {
+				tx.(bolted.WriteTx).Put(dbpath.ToPath("v"), []byte("1"))
+				return nil
+			})
+			require.True(t, errors.Is(err, bbolt.ErrTxNotWritable), "expected tx not writable, got %v", err)
+		})
+	}
+}
@@ -1,17 +1,97 @@
 package bolted
 
 import (
+	"bytes"
 	"context"
+	"fmt"
+	"iter"
 
-	bolt "go.etcd.io/bbolt"
+	"github.com/draganm/bolted/dbpath"
 )
 
+type boundKind int
+
+const (
+	unbounded boundKind = iota
+	inclusiveBound
+	exclusiveBound
+)
+
+// Bound limits the keys visited by a range iterator.
+type Bound struct {
+	key  []byte
+	kind boundKind
+}
+
+// Unbounded returns a bound that does not limit the range.
+func Unbounded() Bound {
+	return Bound{kind: unbounded}
+}
+
+// InclusiveBound returns a bound that includes the key in the range.
+func InclusiveBound(key string) Bound {
+	return Bound{key: []byte(key), kind: inclusiveBound}
+}
+
+// ExclusiveBound returns a bound that excludes the key from the range.
+func ExclusiveBound(key string) Bound {
+	return Bound{key: []byte(key), kind: exclusiveBound}
+}
+
+// prefixBounds returns bounds of the range containing all keys starting with prefix.
+func prefixBounds(prefix string) (Bound, Bound) {
+	lower := InclusiveBound(prefix)
+
+	successor := []byte(prefix)
+	for len(successor) > 0 {
+		last := len(successor) - 1
+		if successor[last] != 0xff {
+			successor[last]++
+			return lower, Bound{key: successor, kind: exclusiveBound}
+		}
+		successor = successor[:last]
+	}
+
+	return lower, Unbounded()
+}
+
+func (b Bound) allowsAbove(k []byte) bool {
+	switch b.kind {
+	case inclusiveBound:
+		return bytes.Compare(k, b.key) >= 0
+	case exclusiveBound:
+		return bytes.Compare(k, b.key) > 0
+	default:
+		return true
+	}
+}
+
+func (b Bound) allowsBelow(k []byte) bool {
+	switch b.kind {
+	case inclusiveBound:
+		return bytes.Compare(k, b.key) <= 0
+	case exclusiveBound:
+		return bytes.Compare(k, b.key) < 0
+	default:
+		return true
+	}
+}
+
 type iterator struct {
-	c     *bolt.Cursor
-	key   string
+	c  storageCursor
+	tx *writeTx
+	// path of the iterated map
+	path  dbpath.Path
+	key   []byte
 	value []byte
+	// blob is set if the current entry is a blob
+	blob  storageBucket
 	done  bool
 	ctx   context.Context
+	lower Bound
+	upper Bound
+	// reverse iterators start at the upper bound and move towards the lower bound on Next
+	reverse bool
 }
 
 func (i iterator) checkForCancelledContext() {
@@ -20,21 +100,183 @@ func (i iterator) checkForCancelledContext() {
 	}
 }
 
+func (i *iterator) set(k, v []byte) {
+	i.key = k
+	i.value = v
+	i.blob = nil
+	i.done = k == nil || !i.lower.allowsAbove(k) || !i.upper.allowsBelow(k)
+	if i.done {
+		i.key = nil
+		i.value = nil
+		return
+	}
+	if v == nil {
+		b := i.c.Bucket().Bucket(k)
+		if isBlob(b) {
+			i.blob = b
+		}
+	}
+}
+
+func (i *iterator) seekLower() {
+	if i.lower.kind == unbounded {
+		i.set(i.c.First())
+		return
+	}
+
+	k, v := i.c.Seek(i.lower.key)
+	if k != nil && i.lower.kind == exclusiveBound && bytes.Equal(k, i.lower.key) {
+		k, v = i.c.Next()
+	}
+	i.set(k, v)
+}
+
+func (i *iterator) seekUpper() {
+	if i.upper.kind == unbounded {
+		i.set(i.c.Last())
+		return
+	}
+
+	k, v := i.c.Seek(i.upper.key)
+	switch {
+	case k == nil:
+		k, v = i.c.Last()
+	case !i.upper.allowsBelow(k):
+		k, v = i.c.Prev()
+	}
+	i.set(k, v)
+}
+
+// logicalKey returns the current key, decrypting it if it is an encrypted path element.
+func (i *iterator) logicalKey() []byte {
+	if i.key == nil || i.tx.pathEncryption == nil {
+		return i.key
+	}
+	p := i.tx.childPath(i.path, i.key)
+	return []byte(p[len(p)-1])
+}
+
 func (i *iterator) GetKey() string {
 	i.checkForCancelledContext()
-	return i.key
+	return string(i.logicalKey())
+}
+
+func (i *iterator) GetKeyBytes() []byte {
+	i.checkForCancelledContext()
+	k := i.logicalKey()
+	if k == nil {
+		return nil
+	}
+	copyOfKey := make([]byte, len(k))
+	copy(copyOfKey, k)
+	return copyOfKey
+}
+
+// decodedValue returns the logical value of the current value entry.
+func (i *iterator) decodedValue() []byte {
+	v, err := i.tx.decodeValue(i.tx.childPath(i.path, i.key), i.value)
+	if err != nil {
+		panic(fmt.Errorf("while decoding value of %q: %w", i.key, err))
+	}
+	return v
 }
 
+// blobValue returns the content of the current blob entry.
+func (i *iterator) blobValue() []byte {
+	v, err := readBlob(i.blob)
+	if err != nil {
+		panic(fmt.Errorf("while reading blob %q: %w", i.key, err))
+	}
+	return v
+}
+
+// GetValue returns a copy of the current value, or nil if the current entry is a map.
 func (i *iterator) GetValue() []byte {
 	i.checkForCancelledContext()
-	copyOfValue := make([]byte, len(i.value))
-	copy(copyOfValue, i.value)
-	return i.value
+	if i.blob != nil {
+		return i.blobValue()
+	}
+	if i.value == nil {
+		return nil
+	}
+	v := i.decodedValue()
+	copyOfValue := make([]byte, len(v))
+	copy(copyOfValue, v)
+	return copyOfValue
 }
 
+// GetRawValue returns the current value without copying it.
+// The returned slice is valid only until the end of the transaction and must not be modified.
+// Blobs are assembled into a new slice.
 func (i *iterator) GetRawValue() []byte {
 	i.checkForCancelledContext()
-	return i.value
+	if i.blob != nil {
+		return i.blobValue()
+	}
+	if i.value == nil {
+		return nil
+	}
+	return i.decodedValue()
+}
+
+func (i *iterator) IsMap() bool {
+	i.checkForCancelledContext()
+	return !i.done && i.value == nil && i.blob == nil
+}
+
+// GetSize returns the number of entries if the current entry is a map,
+// or the length of the value otherwise.
+func (i *iterator) GetSize() uint64 {
+	i.checkForCancelledContext()
+	if i.done {
+		return 0
+	}
+	if i.value != nil {
+		s, err := i.tx.decodedSize(i.tx.childPath(i.path, i.key), i.value)
+		if err != nil {
+			panic(fmt.Errorf("while decoding size of %q: %w", i.key, err))
+		}
+		return s
+	}
+	if i.blob != nil {
+		return blobSize(i.blob)
+	}
+	return i.c.Bucket().Bucket(i.key).Sequence()
+}
+
+// All rewinds the iterator and yields keys and copies of values of all entries.
+// Value is nil for maps.
+func (i *iterator) All() iter.Seq2[string, []byte] {
+	return func(yield func(key string, value []byte) bool) {
+		for i.First(); !i.IsDone(); i.Next() {
+			if !yield(i.GetKey(), i.GetValue()) {
+				return
+			}
+		}
+	}
+}
+
+// Keys rewinds the iterator and yields keys of all entries.
+func (i *iterator) Keys() iter.Seq[string] {
+	return func(yield func(key string) bool) {
+		for i.First(); !i.IsDone(); i.Next() {
+			if !yield(i.GetKey()) {
+				return
+			}
+		}
+	}
+}
+
+// Values rewinds the iterator and yields copies of values of all entries.
+// Value is nil for maps.
+func (i *iterator) Values() iter.Seq[[]byte] {
+	return func(yield func(value []byte) bool) {
+		for i.First(); !i.IsDone(); i.Next() {
+			if !yield(i.GetValue()) {
+				return
+			}
+		}
+	}
 }
 
 func (i *iterator) IsDone() bool {
@@ -44,43 +286,74 @@ func (i *iterator) IsDone() bool {
 
 func (i *iterator) Next() {
 	i.checkForCancelledContext()
-	var k, v []byte
-	k, v = i.c.Next()
-	i.key = string(k)
-	i.value = v
-	i.done = k == nil
+	if i.reverse {
+		i.set(i.c.Prev())
+		return
+	}
+	i.set(i.c.Next())
 }
 
 func (i *iterator) Prev() {
 	i.checkForCancelledContext()
-	var k, v []byte
-	k, v = i.c.Prev()
-	i.key = string(k)
-	i.value = v
-	i.done = k == nil
+	if i.reverse {
+		i.set(i.c.Next())
+		return
+	}
+	i.set(i.c.Prev())
 }
 
+// Seek positions a forward iterator at the first key greater than or equal to the key,
+// and a reverse iterator at the last key less than or equal to the key.
+// Keys outside of the iterator's range are clamped to the range.
+// In maps with encrypted path elements only seeking to existing keys is supported.
 func (i *iterator) Seek(key string) {
 	i.checkForCancelledContext()
-	k, v := i.c.Seek([]byte(key))
-	i.key = string(k)
-	i.value = v
-	i.done = k == nil
+	k := i.tx.storedKey(i.path.Append(key))
+
+	if !i.lower.allowsAbove(k) {
+		if i.reverse {
+			i.set(nil, nil)
+			return
+		}
+		i.seekLower()
+		return
+	}
+
+	if !i.upper.allowsBelow(k) {
+		if i.reverse {
+			i.seekUpper()
+			return
+		}
+		i.set(nil, nil)
+		return
+	}
 
+	ck, v := i.c.Seek(k)
+	if i.reverse {
+		switch {
+		case ck == nil:
+			ck, v = i.c.Last()
+		case !bytes.Equal(ck, k):
+			ck, v = i.c.Prev()
+		}
+	}
+	i.set(ck, v)
 }
 
 func (i *iterator) First() {
 	i.checkForCancelledContext()
-	k, v := i.c.First()
-	i.key = string(k)
-	i.value = v
-	i.done = k == nil
+	if i.reverse {
+		i.seekUpper()
+		return
+	}
+	i.seekLower()
 }
 
 func (i *iterator) Last() {
 	i.checkForCancelledContext()
-	k, v := i.c.Last()
-	i.key = string(k)
-	i.value = v
-	i.done = k == nil
+	if i.reverse {
+		i.seekLower()
+		return
+	}
+	i.seekUpper()
 }
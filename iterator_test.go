@@ -0,0 +1,274 @@
+package bolted_test
+
+import (
+	"testing"
+
+	"github.com/draganm/bolted"
+	"github.com/draganm/bolted/dbpath"
+	"github.com/stretchr/testify/require"
+)
+
+func collectKeys(it bolted.Iterator) []string {
+	keys := []string{}
+	for ; !it.IsDone(); it.Next() {
+		keys = append(keys, it.GetKey())
+	}
+	return keys
+}
+
+func TestRangeIteration(t *testing.T) {
+	bdb, cleanup := openEmptyDatabase(t, bolted.Options{})
+	defer cleanup()
+
+	err := bdb.Write(func(tx bolted.WriteTx) error {
+		tx.CreateMap(dbpath.ToPath("events"))
+		for _, k := range []string{"a", "b", "ba", "bb", "c", "d", "\xff", "\xff\xff"} {
+			tx.Put(dbpath.ToPath("events", k), []byte(k))
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	cases := []struct {
+		name     string
+		iterator func(tx bolted.ReadTx) bolted.Iterator
+		expected []string
+	}{
+		{
+			name: "unbounded range",
+			iterator: func(tx bolted.ReadTx) bolted.Iterator {
+				return tx.IterateRange(dbpath.ToPath("events"), bolted.Unbounded(), bolted.Unbounded())
+			},
+			expected: []string{"a", "b", "ba", "bb", "c", "d", "\xff", "\xff\xff"},
+		},
+		{
+			name: "inclusive bounds",
+			iterator: func(tx bolted.ReadTx) bolted.Iterator {
+				return tx.IterateRange(dbpath.ToPath("events"), bolted.InclusiveBound("b"), bolted.InclusiveBound("c"))
+			},
+			expected: []string{"b", "ba", "bb", "c"},
+		},
+		{
+			name: "exclusive bounds",
+			iterator: func(tx bolted.ReadTx) bolted.Iterator {
+				return tx.IterateRange(dbpath.ToPath("events"), bolted.ExclusiveBound("b"), bolted.ExclusiveBound("c"))
+			},
+			expected: []string{"ba", "bb"},
+		},
+		{
+			name: "bounds between keys",
+			iterator: func(tx bolted.ReadTx) bolted.Iterator {
+				return tx.IterateRange(dbpath.ToPath("events"), bolted.InclusiveBound("aa"), bolted.ExclusiveBound("bc"))
+			},
+			expected: []string{"b", "ba", "bb"},
+		},
+		{
+			name: "empty range",
+			iterator: func(tx bolted.ReadTx) bolted.Iterator {
+				return tx.IterateRange(dbpath.ToPath("events"), bolted.ExclusiveBound("c"), bolted.ExclusiveBound("d"))
+			},
+			expected: []string{},
+		},
+		{
+			name: "reverse range",
+			iterator: func(tx bolted.ReadTx) bolted.Iterator {
+				return tx.IterateRangeReverse(dbpath.ToPath("events"), bolted.ExclusiveBound("a"), bolted.InclusiveBound("c"))
+			},
+			expected: []string{"c", "bb", "ba", "b"},
+		},
+		{
+			name: "reverse range with upper bound after last key",
+			iterator: func(tx bolted.ReadTx) bolted.Iterator {
+				return tx.IterateRangeReverse(dbpath.ToPath("events"), bolted.InclusiveBound("d"), bolted.InclusiveBound("\xff\xff\xff"))
+			},
+			expected: []string{"\xff\xff", "\xff", "d"},
+		},
+		{
+			name: "prefix",
+			iterator: func(tx bolted.ReadTx) bolted.Iterator {
+				return tx.IteratePrefix(dbpath.ToPath("events"), "b")
+			},
+			expected: []string{"b", "ba", "bb"},
+		},
+		{
+			name: "prefix of 0xff",
+			iterator: func(tx bolted.ReadTx) bolted.Iterator {
+				return tx.IteratePrefix(dbpath.ToPath("events"), "\xff")
+			},
+			expected: []string{"\xff", "\xff\xff"},
+		},
+		{
+			name: "reverse prefix",
+			iterator: func(tx bolted.ReadTx) bolted.Iterator {
+				return tx.IteratePrefixReverse(dbpath.ToPath("events"), "b")
+			},
+			expected: []string{"bb", "ba", "b"},
+		},
+		{
+			name: "missing prefix",
+			iterator: func(tx bolted.ReadTx) bolted.Iterator {
+				return tx.IteratePrefix(dbpath.ToPath("events"), "x")
+			},
+			expected: []string{},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := bdb.Read(func(tx bolted.ReadTx) error {
+				require.Equal(t, tc.expected, collectKeys(tc.iterator(tx)))
+				return nil
+			})
+			require.NoError(t, err)
+		})
+	}
+
+	t.Run("seek is clamped to the range", func(t *testing.T) {
+		err := bdb.Read(func(tx bolted.ReadTx) error {
+			it := tx.IterateRange(dbpath.ToPath("events"), bolted.InclusiveBound("b"), bolted.InclusiveBound("c"))
+
+			it.Seek("a")
+			require.Equal(t, "b", it.GetKey())
+
+			it.Seek("bab")
+			require.Equal(t, "bb", it.GetKey())
+
+			it.Seek("ca")
+			require.True(t, it.IsDone())
+
+			it.Last()
+			require.Equal(t, "c", it.GetKey())
+
+			it.Next()
+			require.True(t, it.IsDone())
+			return nil
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("reverse seek", func(t *testing.T) {
+		err := bdb.Read(func(tx bolted.ReadTx) error {
+			it := tx.IterateRangeReverse(dbpath.ToPath("events"), bolted.InclusiveBound("b"), bolted.InclusiveBound("c"))
+
+			it.Seek("bab")
+			require.Equal(t, "ba", it.GetKey())
+
+			it.Seek("z")
+			require.Equal(t, "c", it.GetKey())
+
+			it.Prev()
+			require.True(t, it.IsDone())
+
+			it.Seek("a")
+			require.True(t, it.IsDone())
+			return nil
+		})
+		require.NoError(t, err)
+	})
+}
+
+func TestIteratorEntries(t *testing.T) {
+	bdb, cleanup := openEmptyDatabase(t, bolted.Options{})
+	defer cleanup()
+
+	err := bdb.Write(func(tx bolted.WriteTx) error {
+		tx.CreateMap(dbpath.ToPath("a"))
+		tx.Put(dbpath.ToPath("a", "x"), []byte{1})
+		tx.Put(dbpath.ToPath("a", "y"), []byte{2})
+		tx.Put(dbpath.ToPath("b"), []byte{1, 2, 3})
+		tx.Put(dbpath.ToPath("c"), []byte{})
+		return nil
+	})
+	require.NoError(t, err)
+
+	t.Run("entry kind and size", func(t *testing.T) {
+		err := bdb.Read(func(tx bolted.ReadTx) error {
+			it := tx.Iterate(dbpath.NilPath)
+
+			require.Equal(t, "a", it.GetKey())
+			require.True(t, it.IsMap())
+			require.Nil(t, it.GetValue())
+			require.Equal(t, uint64(2), it.GetSize())
+
+			it.Next()
+			require.Equal(t, "b", it.GetKey())
+			require.False(t, it.IsMap())
+			require.Equal(t, uint64(3), it.GetSize())
+
+			it.Next()
+			require.Equal(t, "c", it.GetKey())
+			require.False(t, it.IsMap())
+			require.Equal(t, []byte{}, it.GetValue())
+			require.Equal(t, uint64(0), it.GetSize())
+
+			it.Next()
+			require.True(t, it.IsDone())
+			require.False(t, it.IsMap())
+			return nil
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("value is copied", func(t *testing.T) {
+		err := bdb.Read(func(tx bolted.ReadTx) error {
+			it := tx.Iterate(dbpath.NilPath)
+			it.Seek("b")
+
+			v := it.GetValue()
+			v[0] = 42
+
+			require.Equal(t, []byte{1, 2, 3}, it.GetValue())
+			require.Equal(t, []byte{1, 2, 3}, it.GetRawValue())
+			return nil
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("all", func(t *testing.T) {
+		err := bdb.Read(func(tx bolted.ReadTx) error {
+			it := tx.Iterate(dbpath.NilPath)
+			it.Last()
+
+			keys := []string{}
+			values := [][]byte{}
+			for key, value := range it.All() {
+				keys = append(keys, key)
+				values = append(values, value)
+			}
+
+			require.Equal(t, []string{"a", "b", "c"}, keys)
+			require.Equal(t, [][]byte{nil, {1, 2, 3}, {}}, values)
+			return nil
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("keys stop early", func(t *testing.T) {
+		err := bdb.Read(func(tx bolted.ReadTx) error {
+			keys := []string{}
+			for key := range tx.Iterate(dbpath.NilPath).Keys() {
+				keys = append(keys, key)
+				if len(keys) == 2 {
+					break
+				}
+			}
+
+			require.Equal(t, []string{"a", "b"}, keys)
+			return nil
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("values of range", func(t *testing.T) {
+		err := bdb.Read(func(tx bolted.ReadTx) error {
+			values := [][]byte{}
+			for value := range tx.IteratePrefix(dbpath.ToPath("a"), "y").Values() {
+				values = append(values, value)
+			}
+
+			require.Equal(t, [][]byte{{2}}, values)
+			return nil
+		})
+		require.NoError(t, err)
+	})
+}
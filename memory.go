@@ -0,0 +1,206 @@
+package bolted
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/draganm/bolted/dbpath"
+	"go.etcd.io/bbolt"
+)
+
+// MemoryDB is a Database keeping all data in memory, with the same semantics as LocalDB.
+// It is meant for tests and ephemeral data.
+type MemoryDB struct {
+	txOptions
+	obs *observer
+
+	// writeMu serializes write transactions
+	writeMu sync.Mutex
+
+	// mu protects the fields below
+	mu     sync.RWMutex
+	top    *memBucket
+	txID   int
+	closed bool
+}
+
+// OpenMemory creates an empty in-memory database.
+// bbolt specific options are ignored.
+func OpenMemory(options Options) (*MemoryDB, error) {
+	txOptions, err := newTxOptions(options)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := &memTx{
+		id:       1,
+		top:      &memBucket{},
+		writable: true,
+	}
+	tx.top.owner = tx
+
+	_, err = tx.CreateBucket([]byte(rootBucketName))
+	if err != nil {
+		return nil, fmt.Errorf("while creating root bucket: %w", err)
+	}
+
+	tx.done = true
+
+	return &MemoryDB{
+		txOptions: txOptions,
+		obs:       newObserver(),
+		top:       tx.top,
+		txID:      tx.id,
+	}, nil
+}
+
+func (m *MemoryDB) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closed = true
+	m.top = nil
+	return nil
+}
+
+func (m *MemoryDB) Stats() (*bbolt.Stats, error) {
+	return &bbolt.Stats{}, nil
+}
+
+// snapshot returns the last committed state of the database.
+func (m *MemoryDB) snapshot() (*memBucket, int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.closed {
+		return nil, 0, bbolt.ErrDatabaseNotOpen
+	}
+	return m.top, m.txID, nil
+}
+
+// runTx calls fn, converting panics raised by transaction methods into errors.
+func runTx(fn func() error) (err error) {
+	defer func() {
+		v := recover()
+		if v == nil {
+			return
+		}
+		re, isError := v.(error)
+		if isError {
+			err = re
+			return
+		}
+		err = fmt.Errorf("panic: %v", v)
+	}()
+
+	return fn()
+}
+
+func (m *MemoryDB) Write(fn func(tx WriteTx) error) error {
+	return m.WriteWithContext(context.Background(), fn)
+}
+
+func (m *MemoryDB) WriteWithContext(ctx context.Context, fn func(tx WriteTx) error) (err error) {
+	ctx, span := tracer.Start(ctx, "Write")
+
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+		}
+		span.End()
+	}()
+
+	m.writeMu.Lock()
+	defer m.writeMu.Unlock()
+
+	top, txID, err := m.snapshot()
+	if err != nil {
+		return err
+	}
+
+	mtx := &memTx{
+		id:       txID + 1,
+		writable: true,
+	}
+	mtx.top = top.copyFor(mtx)
+
+	txObserver := m.obs.newWTxObserver()
+
+	wtx := &writeTx{
+		txOptions:   m.txOptions,
+		btx:         mtx,
+		readOnly:    false,
+		rootBucket:  mtx.Bucket([]byte(rootBucketName)),
+		fillPercent: bbolt.DefaultFillPercent,
+		observer:    txObserver,
+		ctx:         ctx,
+	}
+
+	err = runTx(func() error {
+		err := fn(wtx)
+		if err != nil {
+			return err
+		}
+		return wtx.beforeCommit()
+	})
+
+	// buckets of the transaction must be immutable before they are published to readers
+	mtx.done = true
+
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return bbolt.ErrDatabaseNotOpen
+	}
+	m.top = mtx.top
+	m.txID = mtx.id
+	m.mu.Unlock()
+
+	txObserver.broadcast()
+
+	return nil
+}
+
+func (m *MemoryDB) Read(fn func(tx ReadTx) error) error {
+	return m.ReadWithContext(context.Background(), fn)
+}
+
+func (m *MemoryDB) ReadWithContext(ctx context.Context, fn func(tx ReadTx) error) (err error) {
+	ctx, span := tracer.Start(ctx, "Read")
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+		}
+		span.End()
+	}()
+
+	top, txID, err := m.snapshot()
+	if err != nil {
+		return err
+	}
+
+	mtx := &memTx{
+		id:  txID,
+		top: top,
+	}
+
+	tx := &writeTx{
+		txOptions:   m.txOptions,
+		btx:         mtx,
+		readOnly:    true,
+		rootBucket:  mtx.Bucket([]byte(rootBucketName)),
+		fillPercent: bbolt.DefaultFillPercent,
+		ctx:         ctx,
+	}
+
+	return runTx(func() error {
+		return fn(tx)
+	})
+}
+
+func (m *MemoryDB) Observe(ctx context.Context, path dbpath.Matcher) <-chan ObservedChanges {
+	return m.obs.observe(ctx, path)
+}
@@ -0,0 +1,377 @@
+package bolted
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"slices"
+
+	"go.etcd.io/bbolt"
+)
+
+// memTx is the in-memory counterpart of bbolt.Tx.
+// Committed buckets are never modified. A write transaction copies every bucket it accesses
+// on the first access and the tree nodes it modifies, so read transactions and rolled back
+// write transactions are isolated.
+type memTx struct {
+	id   int
+	top  *memBucket
+	done bool
+	// writable is false for read transactions
+	writable bool
+}
+
+var errMemoryDump = errors.New("in-memory database can't be dumped")
+
+func (t *memTx) Bucket(name []byte) storageBucket {
+	return wrapMemBucket(t.top.bucket(name))
+}
+
+func (t *memTx) CreateBucket(name []byte) (storageBucket, error) {
+	b, err := t.top.createBucket(name)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (t *memTx) ID() int {
+	return t.id
+}
+
+func (t *memTx) Size() int64 {
+	return t.top.size()
+}
+
+func (t *memTx) WriteTo(w io.Writer) (int64, error) {
+	return 0, errMemoryDump
+}
+
+type memEntry struct {
+	key []byte
+	// value is nil for buckets
+	value  []byte
+	bucket *memBucket
+}
+
+type memBucket struct {
+	// root is the root of the tree of entries, nil if the bucket is empty
+	root     *memNode
+	sequence uint64
+	// owner is the transaction that created this copy of the bucket
+	owner *memTx
+}
+
+// wrapMemBucket makes sure that a missing bucket is a nil storageBucket.
+func wrapMemBucket(b *memBucket) storageBucket {
+	if b == nil {
+		return nil
+	}
+	return b
+}
+
+func (b *memBucket) writable() bool {
+	return b.owner != nil && b.owner.writable && !b.owner.done
+}
+
+// copyFor returns a copy of the bucket sharing the tree of entries, whose nodes are copied when modified by the transaction.
+func (b *memBucket) copyFor(tx *memTx) *memBucket {
+	return &memBucket{
+		root:     b.root,
+		sequence: b.sequence,
+		owner:    tx,
+	}
+}
+
+func (b *memBucket) len() int {
+	if b.root == nil {
+		return 0
+	}
+	return b.root.count
+}
+
+func (b *memBucket) entry(i int) memEntry {
+	return b.root.entry(i)
+}
+
+// find returns the index of the first entry with a key greater than or equal to the key.
+func (b *memBucket) find(key []byte) (int, bool) {
+	i := 0
+	n := b.root
+	for n != nil {
+		j, found := slices.BinarySearchFunc(n.entries, key, func(e memEntry, key []byte) int {
+			return bytes.Compare(e.key, key)
+		})
+		i += j
+		if n.leaf() {
+			return i, found
+		}
+		for _, c := range n.children[:j] {
+			i += c.count
+		}
+		if found {
+			return i + n.children[j].count, true
+		}
+		n = n.children[j]
+	}
+	return i, false
+}
+
+func (b *memBucket) set(i int, e memEntry) {
+	b.root = b.root.set(b.owner, i, e)
+}
+
+func (b *memBucket) insert(i int, e memEntry) {
+	if b.root == nil {
+		b.root = &memNode{owner: b.owner}
+	}
+	left, middle, right := b.root.insert(b.owner, i, e)
+	if right == nil {
+		b.root = left
+		return
+	}
+	b.root = &memNode{
+		entries:  []memEntry{middle},
+		children: []*memNode{left, right},
+		count:    left.count + right.count + 1,
+		owner:    b.owner,
+	}
+}
+
+func (b *memBucket) remove(i int) {
+	root, _ := b.root.remove(b.owner, i)
+	switch {
+	case root.count == 0:
+		root = nil
+	case len(root.entries) == 0:
+		root = root.children[0]
+	}
+	b.root = root
+}
+
+func (b *memBucket) Get(key []byte) []byte {
+	i, found := b.find(key)
+	if !found {
+		return nil
+	}
+	return b.entry(i).value
+}
+
+func (b *memBucket) Put(key, value []byte) error {
+	switch {
+	case !b.writable():
+		return bbolt.ErrTxNotWritable
+	case len(key) == 0:
+		return bbolt.ErrKeyRequired
+	case len(key) > bbolt.MaxKeySize:
+		return bbolt.ErrKeyTooLarge
+	case int64(len(value)) > bbolt.MaxValueSize:
+		return bbolt.ErrValueTooLarge
+	}
+
+	// bbolt values are never nil, even if they are empty
+	copyOfValue := make([]byte, len(value))
+	copy(copyOfValue, value)
+
+	i, found := b.find(key)
+	if found {
+		e := b.entry(i)
+		if e.bucket != nil {
+			return bbolt.ErrIncompatibleValue
+		}
+		e.value = copyOfValue
+		b.set(i, e)
+		return nil
+	}
+
+	copyOfKey := make([]byte, len(key))
+	copy(copyOfKey, key)
+
+	b.insert(i, memEntry{key: copyOfKey, value: copyOfValue})
+
+	return nil
+}
+
+func (b *memBucket) Delete(key []byte) error {
+	if !b.writable() {
+		return bbolt.ErrTxNotWritable
+	}
+
+	i, found := b.find(key)
+	if !found {
+		return nil
+	}
+
+	if b.entry(i).bucket != nil {
+		return bbolt.ErrIncompatibleValue
+	}
+
+	b.remove(i)
+
+	return nil
+}
+
+// bucket returns the nested bucket, copying it first if the bucket is modifiable by a write transaction.
+func (b *memBucket) bucket(name []byte) *memBucket {
+	i, found := b.find(name)
+	if !found {
+		return nil
+	}
+
+	e := b.entry(i)
+	if e.bucket == nil {
+		return nil
+	}
+
+	child := e.bucket
+	if b.writable() && child.owner != b.owner {
+		child = child.copyFor(b.owner)
+		e.bucket = child
+		b.set(i, e)
+	}
+
+	return child
+}
+
+func (b *memBucket) Bucket(name []byte) storageBucket {
+	return wrapMemBucket(b.bucket(name))
+}
+
+func (b *memBucket) createBucket(name []byte) (*memBucket, error) {
+	if !b.writable() {
+		return nil, bbolt.ErrTxNotWritable
+	}
+
+	if len(name) == 0 {
+		return nil, bbolt.ErrBucketNameRequired
+	}
+
+	i, found := b.find(name)
+	if found {
+		if b.entry(i).bucket != nil {
+			return nil, bbolt.ErrBucketExists
+		}
+		return nil, bbolt.ErrIncompatibleValue
+	}
+
+	copyOfName := make([]byte, len(name))
+	copy(copyOfName, name)
+
+	child := &memBucket{owner: b.owner}
+	b.insert(i, memEntry{key: copyOfName, bucket: child})
+
+	return child, nil
+}
+
+func (b *memBucket) CreateBucket(name []byte) (storageBucket, error) {
+	child, err := b.createBucket(name)
+	if err != nil {
+		return nil, err
+	}
+	return child, nil
+}
+
+func (b *memBucket) DeleteBucket(name []byte) error {
+	if !b.writable() {
+		return bbolt.ErrTxNotWritable
+	}
+
+	i, found := b.find(name)
+	if !found {
+		return bbolt.ErrBucketNotFound
+	}
+
+	if b.entry(i).bucket == nil {
+		return bbolt.ErrIncompatibleValue
+	}
+
+	b.remove(i)
+
+	return nil
+}
+
+func (b *memBucket) Cursor() storageCursor {
+	return &memCursor{b: b}
+}
+
+func (b *memBucket) Sequence() uint64 {
+	return b.sequence
+}
+
+func (b *memBucket) SetSequence(v uint64) error {
+	if !b.writable() {
+		return bbolt.ErrTxNotWritable
+	}
+	b.sequence = v
+	return nil
+}
+
+func (b *memBucket) NextSequence() (uint64, error) {
+	if !b.writable() {
+		return 0, bbolt.ErrTxNotWritable
+	}
+	b.sequence++
+	return b.sequence, nil
+}
+
+func (b *memBucket) SetFillPercent(float64) {}
+
+func (b *memBucket) size() int64 {
+	s := int64(0)
+	if b.root == nil {
+		return s
+	}
+	b.root.each(func(e memEntry) {
+		s += int64(len(e.key) + len(e.value))
+		if e.bucket != nil {
+			s += e.bucket.size()
+		}
+	})
+	return s
+}
+
+type memCursor struct {
+	b *memBucket
+	i int
+}
+
+func (c *memCursor) Bucket() storageBucket {
+	return c.b
+}
+
+func (c *memCursor) current() ([]byte, []byte) {
+	if c.i < 0 || c.i >= c.b.len() {
+		return nil, nil
+	}
+	e := c.b.entry(c.i)
+	return e.key, e.value
+}
+
+func (c *memCursor) First() ([]byte, []byte) {
+	c.i = 0
+	return c.current()
+}
+
+func (c *memCursor) Last() ([]byte, []byte) {
+	c.i = c.b.len() - 1
+	return c.current()
+}
+
+func (c *memCursor) Next() ([]byte, []byte) {
+	if c.i < c.b.len() {
+		c.i++
+	}
+	return c.current()
+}
+
+func (c *memCursor) Prev() ([]byte, []byte) {
+	if c.i >= 0 {
+		c.i--
+	}
+	return c.current()
+}
+
+func (c *memCursor) Seek(seek []byte) ([]byte, []byte) {
+	c.i, _ = c.b.find(seek)
+	return c.current()
+}
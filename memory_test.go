@@ -0,0 +1,425 @@
+package bolted_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"math/rand"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/draganm/bolted"
+	"github.com/draganm/bolted/dbpath"
+	"github.com/stretchr/testify/require"
+)
+
+func openMemoryDatabase(t testing.TB, opts bolted.Options) (bolted.Database, func()) {
+	db, err := bolted.OpenMemory(opts)
+	require.NoError(t, err)
+	return db, func() {
+		require.NoError(t, db.Close())
+	}
+}
+
+func TestMemoryDBBehavesLikeLocalDB(t *testing.T) {
+	type result struct {
+		Value  []byte
+		Size   uint64
+		Exists bool
+		IsMap  bool
+		Keys   []string
+		Err    string
+	}
+
+	cases := []struct {
+		name string
+		op   func(tx bolted.WriteTx) result
+	}{
+		{
+			name: "get value",
+			op: func(tx bolted.WriteTx) result {
+				return result{Value: tx.Get(dbpath.ToPath("m", "v"))}
+			},
+		},
+		{
+			name: "get empty value",
+			op: func(tx bolted.WriteTx) result {
+				v := tx.Get(dbpath.ToPath("m", "empty"))
+				return result{Value: v, Exists: v != nil}
+			},
+		},
+		{
+			name: "get missing value",
+			op: func(tx bolted.WriteTx) result {
+				tx.Get(dbpath.ToPath("m", "missing"))
+				return result{}
+			},
+		},
+		{
+			name: "get from missing map",
+			op: func(tx bolted.WriteTx) result {
+				tx.Get(dbpath.ToPath("missing", "v"))
+				return result{}
+			},
+		},
+		{
+			name: "size of map",
+			op: func(tx bolted.WriteTx) result {
+				return result{Size: tx.GetSizeOf(dbpath.ToPath("m"))}
+			},
+		},
+		{
+			name: "exists and is map",
+			op: func(tx bolted.WriteTx) result {
+				return result{Exists: tx.Exists(dbpath.ToPath("m", "n")), IsMap: tx.IsMap(dbpath.ToPath("m", "n"))}
+			},
+		},
+		{
+			name: "create existing map",
+			op: func(tx bolted.WriteTx) result {
+				tx.CreateMap(dbpath.ToPath("m"))
+				return result{}
+			},
+		},
+		{
+			name: "create map over value",
+			op: func(tx bolted.WriteTx) result {
+				tx.CreateMap(dbpath.ToPath("m", "v"))
+				return result{}
+			},
+		},
+		{
+			name: "put value over map",
+			op: func(tx bolted.WriteTx) result {
+				tx.Put(dbpath.ToPath("m", "n"), []byte("x"))
+				return result{}
+			},
+		},
+		{
+			name: "delete missing",
+			op: func(tx bolted.WriteTx) result {
+				tx.Delete(dbpath.ToPath("m", "missing"))
+				return result{}
+			},
+		},
+		{
+			name: "delete map and iterate",
+			op: func(tx bolted.WriteTx) result {
+				tx.Delete(dbpath.ToPath("m", "n"))
+				return result{Keys: collectKeys(tx.Iterate(dbpath.ToPath("m"))), Size: tx.GetSizeOf(dbpath.ToPath("m"))}
+			},
+		},
+		{
+			name: "iterate range",
+			op: func(tx bolted.WriteTx) result {
+				return result{Keys: collectKeys(tx.IterateRangeReverse(dbpath.ToPath("m"), bolted.InclusiveBound("e"), bolted.ExclusiveBound("v")))}
+			},
+		},
+		{
+			name: "seek",
+			op: func(tx bolted.WriteTx) result {
+				it := tx.Iterate(dbpath.ToPath("m"))
+				it.Seek("f")
+				return result{Keys: []string{it.GetKey()}, IsMap: it.IsMap()}
+			},
+		},
+	}
+
+	openers := map[string]func(t testing.TB, opts bolted.Options) (bolted.Database, func()){
+		"local":  openEmptyDatabase,
+		"memory": openMemoryDatabase,
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			results := map[string]result{}
+			for name, open := range openers {
+				db, cleanup := open(t, bolted.Options{})
+				err := db.Write(func(tx bolted.WriteTx) error {
+					tx.CreateMap(dbpath.ToPath("m"))
+					tx.Put(dbpath.ToPath("m", "v"), []byte("value"))
+					tx.Put(dbpath.ToPath("m", "empty"), []byte{})
+					tx.CreateMap(dbpath.ToPath("m", "n"))
+					tx.Put(dbpath.ToPath("m", "n", "x"), []byte("x"))
+					return nil
+				})
+				require.NoError(t, err)
+
+				var res result
+				err = db.Write(func(tx bolted.WriteTx) error {
+					res = tc.op(tx)
+					return nil
+				})
+				if err != nil {
+					res.Err = err.Error()
+				}
+				results[name] = res
+				cleanup()
+			}
+			require.Equal(t, results["local"], results["memory"])
+		})
+	}
+}
+
+func TestMemoryDBIsolation(t *testing.T) {
+	db, cleanup := openMemoryDatabase(t, bolted.Options{})
+	defer cleanup()
+
+	m := dbpath.ToPath("m")
+	v := m.Append("v")
+
+	err := db.Write(func(tx bolted.WriteTx) error {
+		tx.CreateMap(m)
+		tx.Put(v, []byte("committed"))
+		return nil
+	})
+	require.NoError(t, err)
+
+	t.Run("uncommitted changes are not visible to readers", func(t *testing.T) {
+		err := db.Write(func(tx bolted.WriteTx) error {
+			tx.Put(v, []byte("uncommitted"))
+			tx.Put(m.Append("w"), []byte("uncommitted"))
+
+			return db.Read(func(rtx bolted.ReadTx) error {
+				require.Equal(t, []byte("committed"), rtx.Get(v))
+				require.False(t, rtx.Exists(m.Append("w")))
+				require.Equal(t, uint64(1), rtx.GetSizeOf(m))
+				return nil
+			})
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("readers keep their snapshot", func(t *testing.T) {
+		err := db.Read(func(rtx bolted.ReadTx) error {
+			err := db.Write(func(tx bolted.WriteTx) error {
+				tx.Delete(m)
+				return nil
+			})
+			require.NoError(t, err)
+
+			require.Equal(t, []byte("uncommitted"), rtx.Get(v))
+			return nil
+		})
+		require.NoError(t, err)
+
+		err = db.Read(func(rtx bolted.ReadTx) error {
+			require.False(t, rtx.Exists(m))
+			return nil
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("failed transactions are rolled back", func(t *testing.T) {
+		failure := errors.New("failure")
+		err := db.Write(func(tx bolted.WriteTx) error {
+			tx.CreateMap(m)
+			return failure
+		})
+		require.Equal(t, failure, err)
+
+		err = db.Read(func(rtx bolted.ReadTx) error {
+			require.False(t, rtx.Exists(m))
+			return nil
+		})
+		require.NoError(t, err)
+	})
+}
+
+func TestMemoryDBObserve(t *testing.T) {
+	db, cleanup := openMemoryDatabase(t, bolted.Options{})
+	defer cleanup()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	updates := db.Observe(ctx, dbpath.MustParseMatcher("m/*"))
+	require.Equal(t, bolted.ObservedChanges{}, <-updates)
+
+	err := db.Write(func(tx bolted.WriteTx) error {
+		tx.CreateMap(dbpath.ToPath("m"))
+		tx.Put(dbpath.ToPath("m", "v"), []byte("value"))
+		return nil
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, bolted.ObservedChanges{
+		{Path: dbpath.ToPath("m", "v"), Type: bolted.ChangeTypeValueSet},
+	}, <-updates)
+}
+
+func TestMemoryDBConcurrentAccess(t *testing.T) {
+	db, cleanup := openMemoryDatabase(t, bolted.Options{})
+	defer cleanup()
+
+	m := dbpath.ToPath("m")
+	a := m.Append("a")
+	b := m.Append("b")
+
+	err := db.Write(func(tx bolted.WriteTx) error {
+		tx.CreateMap(m)
+		tx.Put(a, []byte("0"))
+		tx.Put(b, []byte("0"))
+		return nil
+	})
+	require.NoError(t, err)
+
+	const writers = 4
+	const readers = 4
+	const writes = 50
+
+	errs := make(chan error, writers+readers)
+	done := make(chan struct{})
+
+	wg := &sync.WaitGroup{}
+
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < writes; j++ {
+				err := db.Write(func(tx bolted.WriteTx) error {
+					v := []byte(fmt.Sprintf("%d-%d", i, j))
+					tx.Put(a, v)
+					tx.Put(b, v)
+					return nil
+				})
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(i)
+	}
+
+	readersWG := &sync.WaitGroup{}
+
+	for i := 0; i < readers; i++ {
+		readersWG.Add(1)
+		go func() {
+			defer readersWG.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				err := db.Read(func(tx bolted.ReadTx) error {
+					va := tx.Get(a)
+					vb := tx.Get(b)
+					if string(va) != string(vb) {
+						return fmt.Errorf("inconsistent snapshot: %q != %q", va, vb)
+					}
+					for it := tx.Iterate(m); !it.IsDone(); it.Next() {
+						it.GetValue()
+					}
+					return nil
+				})
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(done)
+	readersWG.Wait()
+	close(errs)
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+}
+
+func TestMemoryDBLargeMaps(t *testing.T) {
+	db, cleanup := openMemoryDatabase(t, bolted.Options{})
+	defer cleanup()
+
+	m := dbpath.ToPath("m")
+
+	err := db.Write(func(tx bolted.WriteTx) error {
+		tx.CreateMap(m)
+		return nil
+	})
+	require.NoError(t, err)
+
+	// contents returns the keys and values of the map in iteration order
+	contents := func(tx bolted.ReadTx) ([]string, map[string]string) {
+		keys := []string{}
+		values := map[string]string{}
+		for k, v := range tx.Iterate(m).All() {
+			keys = append(keys, k)
+			values[k] = string(v)
+		}
+		return keys, values
+	}
+
+	expected := map[string]string{}
+
+	requireContents := func(tx bolted.ReadTx, expected map[string]string) {
+		keys, values := contents(tx)
+		require.True(t, slices.IsSorted(keys))
+		require.Equal(t, expected, values)
+		require.Equal(t, uint64(len(expected)), tx.GetSizeOf(m))
+	}
+
+	rnd := rand.New(rand.NewSource(42))
+	failure := errors.New("failure")
+
+	for round := 0; round < 50; round++ {
+		snapshot := maps.Clone(expected)
+
+		err := db.Read(func(rtx bolted.ReadTx) error {
+			changed := maps.Clone(expected)
+			err := db.Write(func(tx bolted.WriteTx) error {
+				for i := 0; i < 500; i++ {
+					k := fmt.Sprintf("%05d", rnd.Intn(20000))
+					if _, found := changed[k]; found && rnd.Intn(3) > 0 {
+						tx.Delete(m.Append(k))
+						delete(changed, k)
+						continue
+					}
+					v := fmt.Sprintf("%d-%d", round, i)
+					tx.Put(m.Append(k), []byte(v))
+					changed[k] = v
+				}
+				requireContents(tx, changed)
+				if round%5 == 4 {
+					return failure
+				}
+				return nil
+			})
+			if err == nil {
+				expected = changed
+			} else {
+				require.Equal(t, failure, err)
+			}
+
+			requireContents(rtx, snapshot)
+			return nil
+		})
+		require.NoError(t, err)
+
+		err = db.Read(func(tx bolted.ReadTx) error {
+			requireContents(tx, expected)
+			return nil
+		})
+		require.NoError(t, err)
+	}
+
+	t.Run("deleting all entries", func(t *testing.T) {
+		err := db.Write(func(tx bolted.WriteTx) error {
+			for k := range expected {
+				tx.Delete(m.Append(k))
+			}
+			requireContents(tx, map[string]string{})
+			return nil
+		})
+		require.NoError(t, err)
+	})
+}
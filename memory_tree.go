@@ -0,0 +1,244 @@
+package bolted
+
+import "slices"
+
+// memNodeMaxEntries is the maximal number of entries of a node of the tree holding entries of a memBucket.
+// Nodes other than the root have at least half as many entries.
+const memNodeMaxEntries = 64
+
+const memNodeMinEntries = memNodeMaxEntries / 2
+
+// memNode is a node of a persistent B-tree of entries sorted by key.
+// Nodes are modified in place only by the transaction owning them, any other transaction
+// copies the nodes on the path to the modified entry, so a write costs O(log n).
+// Entries are addressed by their index, nodes count the entries of their subtrees to find it.
+type memNode struct {
+	entries []memEntry
+	// children is nil for leaves, otherwise it has one element more than entries
+	children []*memNode
+	// count is the number of entries in the subtree
+	count int
+	owner *memTx
+}
+
+func (n *memNode) leaf() bool {
+	return n.children == nil
+}
+
+// mutableFor returns the node itself if it's owned by the transaction, or its copy owned by the transaction.
+func (n *memNode) mutableFor(owner *memTx) *memNode {
+	if n.owner == owner {
+		return n
+	}
+	c := &memNode{
+		entries: slices.Clone(n.entries),
+		count:   n.count,
+		owner:   owner,
+	}
+	if !n.leaf() {
+		c.children = slices.Clone(n.children)
+	}
+	return c
+}
+
+// locate finds the entry or the child holding the entry with index i of the subtree.
+// If the entry is an entry of the node, its position in entries is returned with true,
+// otherwise the position of the child in children and the index of the entry within the child.
+func (n *memNode) locate(i int) (int, int, bool) {
+	if n.leaf() {
+		return i, 0, true
+	}
+	for j, c := range n.children {
+		if i < c.count {
+			return j, i, false
+		}
+		i -= c.count
+		if i == 0 {
+			return j, 0, true
+		}
+		i--
+	}
+	panic("entry index out of range")
+}
+
+func (n *memNode) entry(i int) memEntry {
+	for {
+		j, ci, own := n.locate(i)
+		if own {
+			return n.entries[j]
+		}
+		n, i = n.children[j], ci
+	}
+}
+
+func (n *memNode) set(owner *memTx, i int, e memEntry) *memNode {
+	n = n.mutableFor(owner)
+	j, ci, own := n.locate(i)
+	if own {
+		n.entries[j] = e
+	} else {
+		n.children[j] = n.children[j].set(owner, ci, e)
+	}
+	return n
+}
+
+// insert inserts the entry so that it has index i in the subtree.
+// If the node overflows, it's split and the middle entry and the right half are returned.
+func (n *memNode) insert(owner *memTx, i int, e memEntry) (*memNode, memEntry, *memNode) {
+	n = n.mutableFor(owner)
+	n.count++
+
+	if n.leaf() {
+		n.entries = slices.Insert(n.entries, i, e)
+	} else {
+		// an entry inserted right before a separator is appended to the child left of it
+		j := 0
+		for ; j < len(n.entries) && i > n.children[j].count; j++ {
+			i -= n.children[j].count + 1
+		}
+		child, middle, right := n.children[j].insert(owner, i, e)
+		n.children[j] = child
+		if right != nil {
+			n.entries = slices.Insert(n.entries, j, middle)
+			n.children = slices.Insert(n.children, j+1, right)
+		}
+	}
+
+	if len(n.entries) <= memNodeMaxEntries {
+		return n, memEntry{}, nil
+	}
+
+	return n.split()
+}
+
+func (n *memNode) split() (*memNode, memEntry, *memNode) {
+	m := len(n.entries) / 2
+	middle := n.entries[m]
+
+	right := &memNode{
+		entries: slices.Clone(n.entries[m+1:]),
+		count:   len(n.entries) - m - 1,
+		owner:   n.owner,
+	}
+	clear(n.entries[m:])
+	n.entries = n.entries[:m]
+
+	if !n.leaf() {
+		right.children = slices.Clone(n.children[m+1:])
+		for _, c := range right.children {
+			right.count += c.count
+		}
+		clear(n.children[m+1:])
+		n.children = n.children[:m+1]
+	}
+
+	n.count -= right.count + 1
+
+	return n, middle, right
+}
+
+// remove removes the entry with index i from the subtree.
+// The node may underflow, which is fixed by the parent.
+func (n *memNode) remove(owner *memTx, i int) (*memNode, memEntry) {
+	n = n.mutableFor(owner)
+	n.count--
+
+	j, ci, own := n.locate(i)
+
+	if n.leaf() {
+		removed := n.entries[j]
+		n.entries = slices.Delete(n.entries, j, j+1)
+		return n, removed
+	}
+
+	var removed memEntry
+	if own {
+		// the entry is replaced with its predecessor, the last entry of the child left of it
+		removed = n.entries[j]
+		n.children[j], n.entries[j] = n.children[j].remove(owner, n.children[j].count-1)
+	} else {
+		n.children[j], removed = n.children[j].remove(owner, ci)
+	}
+
+	n.rebalance(owner, j)
+
+	return n, removed
+}
+
+// rebalance fixes the underflow of the j-th child by moving an entry from a sibling or merging it with one.
+func (n *memNode) rebalance(owner *memTx, j int) {
+	if len(n.children[j].entries) >= memNodeMinEntries {
+		return
+	}
+
+	switch {
+	case j > 0 && len(n.children[j-1].entries) > memNodeMinEntries:
+		left := n.children[j-1].mutableFor(owner)
+		child := n.children[j].mutableFor(owner)
+		n.children[j-1], n.children[j] = left, child
+
+		last := len(left.entries) - 1
+		child.entries = slices.Insert(child.entries, 0, n.entries[j-1])
+		n.entries[j-1] = left.entries[last]
+		left.entries = slices.Delete(left.entries, last, last+1)
+		moved := 1
+
+		if !left.leaf() {
+			c := left.children[len(left.children)-1]
+			child.children = slices.Insert(child.children, 0, c)
+			left.children = slices.Delete(left.children, len(left.children)-1, len(left.children))
+			moved += c.count
+		}
+
+		left.count -= moved
+		child.count += moved
+
+	case j < len(n.entries) && len(n.children[j+1].entries) > memNodeMinEntries:
+		child := n.children[j].mutableFor(owner)
+		right := n.children[j+1].mutableFor(owner)
+		n.children[j], n.children[j+1] = child, right
+
+		child.entries = append(child.entries, n.entries[j])
+		n.entries[j] = right.entries[0]
+		right.entries = slices.Delete(right.entries, 0, 1)
+		moved := 1
+
+		if !right.leaf() {
+			c := right.children[0]
+			child.children = append(child.children, c)
+			right.children = slices.Delete(right.children, 0, 1)
+			moved += c.count
+		}
+
+		right.count -= moved
+		child.count += moved
+
+	default:
+		if j == len(n.entries) {
+			j--
+		}
+		left := n.children[j].mutableFor(owner)
+		right := n.children[j+1]
+
+		left.entries = append(left.entries, n.entries[j])
+		left.entries = append(left.entries, right.entries...)
+		left.children = append(left.children, right.children...)
+		left.count += right.count + 1
+
+		n.children[j] = left
+		n.entries = slices.Delete(n.entries, j, j+1)
+		n.children = slices.Delete(n.children, j+1, j+2)
+	}
+}
+
+func (n *memNode) each(fn func(e memEntry)) {
+	for j, e := range n.entries {
+		if !n.leaf() {
+			n.children[j].each(fn)
+		}
+		fn(e)
+	}
+	if !n.leaf() {
+		n.children[len(n.children)-1].each(fn)
+	}
+}
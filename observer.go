@@ -10,14 +10,32 @@ import (
 type observer struct {
 	mu              *sync.RWMutex
 	receivers       map[int]*receiver
+	index           *dbpath.MatcherIndex
 	nextReceiverKey int
 }
 
 func (o *observer) broadcastChanges(changes ObservedChanges) {
 	o.mu.RLock()
 	defer o.mu.RUnlock()
-	for _, r := range o.receivers {
-		r.notify(changes)
+
+	matchingChanges := map[int]ObservedChanges{}
+
+	for _, ch := range changes {
+		if ch.Type == ChangeTypeDeleted {
+			// deletion of a parent affects every receiver observing its children
+			for k := range o.receivers {
+				matchingChanges[k] = matchingChanges[k].Update(ch.Path, ch.Type)
+			}
+			continue
+		}
+
+		for _, k := range o.index.Match(ch.Path) {
+			matchingChanges[k] = matchingChanges[k].Update(ch.Path, ch.Type)
+		}
+	}
+
+	for k, mc := range matchingChanges {
+		o.receivers[k].notify(mc)
 	}
 }
 
@@ -28,15 +46,7 @@ type receiver struct {
 	incoming   chan<- ObservedChanges
 }
 
-func (r *receiver) notify(changes ObservedChanges) {
-
-	matchingChanges := ObservedChanges{}
-
-	for _, ch := range changes {
-		if ch.Type == ChangeTypeDeleted || r.m.Matches(ch.Path) {
-			matchingChanges = matchingChanges.Update(ch.Path, ch.Type)
-		}
-	}
+func (r *receiver) notify(matchingChanges ObservedChanges) {
 
 	if len(matchingChanges) == 0 {
 		return
@@ -103,6 +113,7 @@ func newObserver() *observer {
 	return &observer{
 		mu:        new(sync.RWMutex),
 		receivers: make(map[int]*receiver),
+		index:     dbpath.NewMatcherIndex(),
 	}
 }
 
@@ -111,6 +122,7 @@ func (w *observer) observe(ctx context.Context, m dbpath.Matcher) <-chan Observe
 	receiver, changesChan := newReceiver(m)
 	receiverKey := w.nextReceiverKey
 	w.receivers[receiverKey] = receiver
+	w.index.Add(m, receiverKey)
 	w.nextReceiverKey++
 	w.mu.Unlock()
 
@@ -120,6 +132,7 @@ func (w *observer) observe(ctx context.Context, m dbpath.Matcher) <-chan Observe
 		defer w.mu.Unlock()
 
 		delete(w.receivers, receiverKey)
+		w.index.Remove(m, receiverKey)
 		receiver.close()
 
 	}()
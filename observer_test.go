@@ -136,3 +136,36 @@ func TestObservePath(t *testing.T) {
 	})
 
 }
+
+func TestObserveWithPatternMatcher(t *testing.T) {
+
+	bdb, cleanupDatabase := openEmptyDatabase(t, bolted.Options{})
+	defer cleanupDatabase()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	updates := bdb.Observe(ctx, dbpath.MustParseMatcher("logs/!tmp-*/{info,error}"))
+
+	<-updates
+
+	err := bdb.Write(func(tx bolted.WriteTx) error {
+		tx.CreateMap(dbpath.ToPath("logs"))
+		tx.CreateMap(dbpath.ToPath("logs", "tmp-1"))
+		tx.Put(dbpath.ToPath("logs", "tmp-1", "info"), []byte{1})
+		tx.CreateMap(dbpath.ToPath("logs", "app"))
+		tx.Put(dbpath.ToPath("logs", "app", "info"), []byte{1})
+		tx.Put(dbpath.ToPath("logs", "app", "debug"), []byte{1})
+		return nil
+	})
+	require.NoError(t, err)
+
+	ev := <-updates
+
+	require.Equal(t, bolted.ObservedChanges{
+		bolted.ObservedChange{
+			Path: dbpath.ToPath("logs", "app", "info"),
+			Type: bolted.ChangeTypeValueSet,
+		},
+	}, ev)
+}
@@ -0,0 +1,187 @@
+package bolted
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/draganm/bolted/dbpath"
+)
+
+var ErrInvalidPageToken = errors.New("invalid page token")
+
+func IsInvalidPageToken(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	return errors.Is(err, ErrInvalidPageToken)
+}
+
+type PageEntry struct {
+	Key   string
+	Value []byte
+	IsMap bool
+}
+
+type PageResult struct {
+	Entries []PageEntry
+	// NextToken continues listing after the last entry, it is empty when there are no more entries.
+	NextToken string
+}
+
+// Page tokens are encrypted and authenticated with AES-GCM, using the path of the map as associated data,
+// so they are opaque to clients and can't be used for other maps.
+const pageTokenVersion = 2
+
+const (
+	pageDirectionForward  = 0
+	pageDirectionBackward = 1
+)
+
+// newPageTokenCipher returns the cipher of page tokens derived from key, or from a random key if key is empty.
+func newPageTokenCipher(key []byte) (cipher.AEAD, error) {
+	if len(key) == 0 {
+		key = make([]byte, sha256.Size)
+		_, err := rand.Read(key)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("bolted page token"))
+
+	block, err := aes.NewCipher(mac.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func pageTokenAdditionalData(path dbpath.Path) []byte {
+	return append([]byte{pageTokenVersion}, path.String()...)
+}
+
+func encodePageToken(aead cipher.AEAD, path dbpath.Path, direction byte, lastKey []byte) (string, error) {
+	nonce := make([]byte, aead.NonceSize())
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return "", err
+	}
+
+	plaintext := make([]byte, 0, 1+len(lastKey))
+	plaintext = append(plaintext, direction)
+	plaintext = append(plaintext, lastKey...)
+
+	token := make([]byte, 0, 1+len(nonce)+len(plaintext)+aead.Overhead())
+	token = append(token, pageTokenVersion)
+	token = append(token, nonce...)
+	token = aead.Seal(token, nonce, plaintext, pageTokenAdditionalData(path))
+
+	return base64.RawURLEncoding.EncodeToString(token), nil
+}
+
+func decodePageToken(aead cipher.AEAD, path dbpath.Path, token string) (direction byte, lastKey []byte, err error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, nil, fmt.Errorf("%w: %s", ErrInvalidPageToken, err.Error())
+	}
+
+	if len(data) < 1+aead.NonceSize()+aead.Overhead()+1 {
+		return 0, nil, fmt.Errorf("%w: too short", ErrInvalidPageToken)
+	}
+
+	if data[0] != pageTokenVersion {
+		return 0, nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidPageToken, data[0])
+	}
+
+	nonce := data[1 : 1+aead.NonceSize()]
+	sealed := data[1+aead.NonceSize():]
+
+	plaintext, err := aead.Open(nil, nonce, sealed, pageTokenAdditionalData(path))
+	if err != nil {
+		return 0, nil, fmt.Errorf("%w: token can't be decrypted", ErrInvalidPageToken)
+	}
+
+	return plaintext[0], plaintext[1:], nil
+}
+
+func (w *writeTx) Page(path dbpath.Path, token string, limit int) PageResult {
+	w.checkForCancelledContext()
+	res, err := w.page(path, token, limit, pageDirectionForward)
+	if err != nil {
+		raiseErrorForPath(path, "Page", err)
+	}
+	return res
+}
+
+func (w *writeTx) PageReverse(path dbpath.Path, token string, limit int) PageResult {
+	w.checkForCancelledContext()
+	res, err := w.page(path, token, limit, pageDirectionBackward)
+	if err != nil {
+		raiseErrorForPath(path, "PageReverse", err)
+	}
+	return res
+}
+
+func (w *writeTx) page(path dbpath.Path, token string, limit int, direction byte) (PageResult, error) {
+	if limit <= 0 {
+		return PageResult{}, errors.New("limit must be positive")
+	}
+
+	from, to := Unbounded(), Unbounded()
+
+	if token != "" {
+		tokenDirection, lastKey, err := decodePageToken(w.pageTokens, path, token)
+		if err != nil {
+			return PageResult{}, err
+		}
+
+		if tokenDirection != direction {
+			return PageResult{}, fmt.Errorf("%w: token was issued for the opposite direction", ErrInvalidPageToken)
+		}
+
+		if direction == pageDirectionForward {
+			from = Bound{key: lastKey, kind: exclusiveBound}
+		} else {
+			to = Bound{key: lastKey, kind: exclusiveBound}
+		}
+	}
+
+	it, err := w.newIterator(path, from, to, direction == pageDirectionBackward)
+	if err != nil {
+		return PageResult{}, err
+	}
+
+	res := PageResult{
+		Entries: []PageEntry{},
+	}
+
+	// the stored key is used for the token, since it can differ from the key of encrypted path elements
+	var lastKey []byte
+
+	for ; !it.IsDone() && len(res.Entries) < limit; it.Next() {
+		lastKey = it.key
+		res.Entries = append(res.Entries, PageEntry{
+			Key:   it.GetKey(),
+			Value: it.GetValue(),
+			IsMap: it.IsMap(),
+		})
+	}
+
+	if !it.IsDone() {
+		res.NextToken, err = encodePageToken(w.pageTokens, path, direction, lastKey)
+		if err != nil {
+			return PageResult{}, fmt.Errorf("while encoding page token: %w", err)
+		}
+	}
+
+	return res, nil
+}
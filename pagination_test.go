@@ -0,0 +1,185 @@
+package bolted_test
+
+import (
+	"encoding/base64"
+	"fmt"
+	"testing"
+
+	"github.com/draganm/bolted"
+	"github.com/draganm/bolted/dbpath"
+	"github.com/stretchr/testify/require"
+)
+
+func pageKeys(p bolted.PageResult) []string {
+	keys := []string{}
+	for _, e := range p.Entries {
+		keys = append(keys, e.Key)
+	}
+	return keys
+}
+
+func TestPage(t *testing.T) {
+	bdb, cleanup := openEmptyDatabase(t, bolted.Options{})
+	defer cleanup()
+
+	items := dbpath.ToPath("items")
+
+	err := bdb.Write(func(tx bolted.WriteTx) error {
+		tx.CreateMap(items)
+		for i := 0; i < 5; i++ {
+			tx.Put(items.Append(fmt.Sprintf("%02d", i)), []byte{byte(i)})
+		}
+		tx.CreateMap(items.Append("map"))
+		return nil
+	})
+	require.NoError(t, err)
+
+	readPage := func(reverse bool, token string, limit int) (p bolted.PageResult, err error) {
+		err = bdb.Read(func(tx bolted.ReadTx) error {
+			if reverse {
+				p = tx.PageReverse(items, token, limit)
+			} else {
+				p = tx.Page(items, token, limit)
+			}
+			return nil
+		})
+		return p, err
+	}
+
+	t.Run("pages forward", func(t *testing.T) {
+		p, err := readPage(false, "", 2)
+		require.NoError(t, err)
+		require.Equal(t, []string{"00", "01"}, pageKeys(p))
+		require.Equal(t, []byte{0}, p.Entries[0].Value)
+		require.NotEmpty(t, p.NextToken)
+
+		p, err = readPage(false, p.NextToken, 2)
+		require.NoError(t, err)
+		require.Equal(t, []string{"02", "03"}, pageKeys(p))
+
+		p, err = readPage(false, p.NextToken, 2)
+		require.NoError(t, err)
+		require.Equal(t, []string{"04", "map"}, pageKeys(p))
+		require.True(t, p.Entries[1].IsMap)
+		require.Nil(t, p.Entries[1].Value)
+		require.Empty(t, p.NextToken)
+	})
+
+	t.Run("pages backward", func(t *testing.T) {
+		p, err := readPage(true, "", 3)
+		require.NoError(t, err)
+		require.Equal(t, []string{"map", "04", "03"}, pageKeys(p))
+
+		p, err = readPage(true, p.NextToken, 3)
+		require.NoError(t, err)
+		require.Equal(t, []string{"02", "01", "00"}, pageKeys(p))
+		require.Empty(t, p.NextToken)
+	})
+
+	t.Run("resumes after inserts and deletes", func(t *testing.T) {
+		p, err := readPage(false, "", 2)
+		require.NoError(t, err)
+
+		err = bdb.Write(func(tx bolted.WriteTx) error {
+			tx.Delete(items.Append("01"))
+			tx.Delete(items.Append("02"))
+			tx.Put(items.Append("015"), []byte{42})
+			return nil
+		})
+		require.NoError(t, err)
+
+		p, err = readPage(false, p.NextToken, 2)
+		require.NoError(t, err)
+		require.Equal(t, []string{"015", "03"}, pageKeys(p))
+	})
+
+	t.Run("tampered token", func(t *testing.T) {
+		p, err := readPage(false, "", 1)
+		require.NoError(t, err)
+
+		tampered := []byte(p.NextToken)
+		tampered[3] ^= 1
+
+		_, err = readPage(false, string(tampered), 1)
+		require.True(t, bolted.IsInvalidPageToken(err))
+	})
+
+	t.Run("token for opposite direction", func(t *testing.T) {
+		p, err := readPage(false, "", 1)
+		require.NoError(t, err)
+
+		_, err = readPage(true, p.NextToken, 1)
+		require.True(t, bolted.IsInvalidPageToken(err))
+	})
+
+	t.Run("token for another path", func(t *testing.T) {
+		p, err := readPage(false, "", 1)
+		require.NoError(t, err)
+
+		err = bdb.Read(func(tx bolted.ReadTx) error {
+			tx.Page(dbpath.NilPath, p.NextToken, 1)
+			return nil
+		})
+		require.True(t, bolted.IsInvalidPageToken(err))
+	})
+
+	t.Run("invalid limit", func(t *testing.T) {
+		_, err := readPage(false, "", 0)
+		require.Error(t, err)
+	})
+}
+
+func TestPageTokenKey(t *testing.T) {
+	key := []byte("page token key")
+
+	writeItems := func(db bolted.Database) {
+		err := db.Write(func(tx bolted.WriteTx) error {
+			tx.CreateMap(dbpath.ToPath("items"))
+			for _, k := range []string{"secret-a", "secret-b", "secret-c"} {
+				tx.Put(dbpath.ToPath("items", k), []byte(k))
+			}
+			return nil
+		})
+		require.NoError(t, err)
+	}
+
+	readPage := func(db bolted.Database, token string) (p bolted.PageResult, err error) {
+		err = db.Read(func(tx bolted.ReadTx) error {
+			p = tx.Page(dbpath.ToPath("items"), token, 1)
+			return nil
+		})
+		return p, err
+	}
+
+	db1, cleanup1 := openMemoryDatabase(t, bolted.Options{PageTokenKey: key})
+	defer cleanup1()
+	writeItems(db1)
+
+	db2, cleanup2 := openMemoryDatabase(t, bolted.Options{PageTokenKey: key})
+	defer cleanup2()
+	writeItems(db2)
+
+	p, err := readPage(db1, "")
+	require.NoError(t, err)
+
+	t.Run("token does not reveal the last key", func(t *testing.T) {
+		decoded, err := base64.RawURLEncoding.DecodeString(p.NextToken)
+		require.NoError(t, err)
+		require.NotContains(t, string(decoded), "secret-a")
+	})
+
+	t.Run("token is accepted by database with the same key", func(t *testing.T) {
+		next, err := readPage(db2, p.NextToken)
+		require.NoError(t, err)
+		require.Equal(t, []string{"secret-b"}, pageKeys(next))
+	})
+
+	t.Run("token is rejected by database with another key", func(t *testing.T) {
+		db3, cleanup3 := openMemoryDatabase(t, bolted.Options{})
+		defer cleanup3()
+		writeItems(db3)
+
+		_, err := readPage(db3, p.NextToken)
+		require.True(t, bolted.IsInvalidPageToken(err))
+	})
+}
@@ -0,0 +1,103 @@
+package bolted
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+
+	"github.com/draganm/bolted/dbpath"
+)
+
+// Path elements are encrypted deterministically, so maps and values can still be looked up by exact path.
+// The first 16 bytes of HMAC-SHA256 of the parent path and the element are used as the IV for AES-CTR
+// encryption of the element (SIV construction), and stored in front of the encrypted element.
+// Stored keys don't preserve the order of elements.
+const sivSize = 16
+
+type pathEncryption struct {
+	matcher dbpath.Matcher
+	block   cipher.Block
+	macKey  []byte
+}
+
+func deriveKey(key []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
+
+func newPathEncryption(matcher dbpath.Matcher, key []byte) (*pathEncryption, error) {
+	if matcher == nil {
+		return nil, nil
+	}
+
+	if len(key) < 16 {
+		return nil, errors.New("path element key must have at least 16 bytes")
+	}
+
+	block, err := aes.NewCipher(deriveKey(key, "bolted path element encryption"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &pathEncryption{
+		matcher: matcher,
+		block:   block,
+		macKey:  deriveKey(key, "bolted path element authentication"),
+	}, nil
+}
+
+func (pe *pathEncryption) siv(parent dbpath.Path, element []byte) []byte {
+	mac := hmac.New(sha256.New, pe.macKey)
+	mac.Write([]byte(parent.String()))
+	mac.Write([]byte{0})
+	mac.Write(element)
+	return mac.Sum(nil)[:sivSize]
+}
+
+func (pe *pathEncryption) encrypt(parent dbpath.Path, element string) []byte {
+	siv := pe.siv(parent, []byte(element))
+	k := make([]byte, sivSize+len(element))
+	copy(k, siv)
+	cipher.NewCTR(pe.block, siv).XORKeyStream(k[sivSize:], []byte(element))
+	return k
+}
+
+// decrypt returns the element stored under the key k, or false if k is not an encrypted element of parent.
+func (pe *pathEncryption) decrypt(parent dbpath.Path, k []byte) (string, bool) {
+	if len(k) < sivSize {
+		return "", false
+	}
+
+	element := make([]byte, len(k)-sivSize)
+	cipher.NewCTR(pe.block, k[:sivSize]).XORKeyStream(element, k[sivSize:])
+
+	if !hmac.Equal(pe.siv(parent, element), k[:sivSize]) {
+		return "", false
+	}
+
+	return string(element), true
+}
+
+// storedKey returns the key under which the last element of the path is stored in its parent map.
+func (w *writeTx) storedKey(path dbpath.Path) []byte {
+	last := path[len(path)-1]
+	if w.pathEncryption == nil || !w.pathEncryption.matcher.Matches(path) {
+		return []byte(last)
+	}
+	return w.pathEncryption.encrypt(path[:len(path)-1], last)
+}
+
+// childPath returns the path of the child stored under the key k in the map at parent.
+// Keys that are not encrypted, for example because they were stored before encryption was enabled, are used as they are.
+func (w *writeTx) childPath(parent dbpath.Path, k []byte) dbpath.Path {
+	if w.pathEncryption != nil {
+		e, decrypted := w.pathEncryption.decrypt(parent, k)
+		if decrypted {
+			return parent.Append(e)
+		}
+	}
+	return parent.AppendBytes(k)
+}
@@ -0,0 +1,153 @@
+package bolted_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/draganm/bolted"
+	"github.com/draganm/bolted/dbpath"
+	"github.com/stretchr/testify/require"
+	"go.etcd.io/bbolt"
+)
+
+// storedKeys returns all bucket and value keys stored in the bolt database file.
+func storedKeys(t *testing.T, dbFile string) [][]byte {
+	db, err := bbolt.Open(dbFile, 0660, nil)
+	require.NoError(t, err)
+	defer db.Close()
+
+	keys := [][]byte{}
+
+	var collect func(b *bbolt.Bucket)
+	collect = func(b *bbolt.Bucket) {
+		c := b.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			keys = append(keys, append([]byte(nil), k...))
+			if v == nil {
+				collect(b.Bucket(k))
+			}
+		}
+	}
+
+	err = db.View(func(tx *bbolt.Tx) error {
+		collect(tx.Bucket([]byte("root")))
+		return nil
+	})
+	require.NoError(t, err)
+
+	return keys
+}
+
+func TestEncryptedPathElements(t *testing.T) {
+	td, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(td)
+
+	dbFile := filepath.Join(td, "db")
+
+	customers := dbpath.ToPath("customers")
+	alice := customers.Append("alice@example.com")
+	bob := customers.Append("bob@example.com")
+
+	options := bolted.Options{
+		EncryptedPathElements: dbpath.MustParseMatcher("customers/*/**"),
+		PathElementKey:        bytes.Repeat([]byte{1}, 32),
+	}
+
+	t.Run("exact paths work", func(t *testing.T) {
+		bdb, err := bolted.Open(dbFile, 0660, options)
+		require.NoError(t, err)
+		defer bdb.Close()
+
+		err = bdb.Write(func(tx bolted.WriteTx) error {
+			tx.CreateMap(customers)
+			tx.CreateMap(alice)
+			tx.Put(alice.Append("email"), []byte("alice@example.com"))
+			tx.Put(bob, []byte("bob"))
+			return nil
+		})
+		require.NoError(t, err)
+
+		err = bdb.Read(func(tx bolted.ReadTx) error {
+			require.True(t, tx.Exists(alice))
+			require.True(t, tx.IsMap(alice))
+			require.Equal(t, []byte("alice@example.com"), tx.Get(alice.Append("email")))
+			require.Equal(t, []byte("bob"), tx.Get(bob))
+			require.False(t, tx.Exists(customers.Append("carol@example.com")))
+			return nil
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("keys are decrypted when listing", func(t *testing.T) {
+		bdb, err := bolted.Open(dbFile, 0660, options)
+		require.NoError(t, err)
+		defer bdb.Close()
+
+		err = bdb.Read(func(tx bolted.ReadTx) error {
+			keys := collectKeys(tx.Iterate(customers))
+			sort.Strings(keys)
+			require.Equal(t, []string{"alice@example.com", "bob@example.com"}, keys)
+
+			require.ElementsMatch(t, []dbpath.Path{alice.Append("email")}, tx.Find(dbpath.MustParseMatcher("customers/*/email")))
+			require.ElementsMatch(t, []dbpath.Path{bob}, tx.Find(dbpath.MustParseMatcher("customers/bob@example.com")))
+
+			walked := []dbpath.Path{}
+			err := tx.Walk(customers, func(path dbpath.Path, _ bool, _ []byte) error {
+				walked = append(walked, path)
+				return nil
+			})
+			require.NoError(t, err)
+			require.ElementsMatch(t, []dbpath.Path{customers, alice, alice.Append("email"), bob}, walked)
+
+			paged := []string{}
+			token := ""
+			for {
+				page := tx.Page(customers, token, 1)
+				for _, e := range page.Entries {
+					paged = append(paged, e.Key)
+				}
+				token = page.NextToken
+				if token == "" {
+					break
+				}
+			}
+			require.ElementsMatch(t, keys, paged)
+			return nil
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("encrypted keys are opaque on disk", func(t *testing.T) {
+		keys := storedKeys(t, dbFile)
+		require.Len(t, keys, 4)
+		for _, k := range keys {
+			require.False(t, bytes.Contains(k, []byte("alice")))
+			require.False(t, bytes.Contains(k, []byte("bob")))
+			require.False(t, bytes.Equal(k, []byte("email")))
+		}
+	})
+
+	t.Run("encrypted paths can be deleted", func(t *testing.T) {
+		bdb, err := bolted.Open(dbFile, 0660, options)
+		require.NoError(t, err)
+		defer bdb.Close()
+
+		err = bdb.Write(func(tx bolted.WriteTx) error {
+			tx.Delete(alice)
+			return nil
+		})
+		require.NoError(t, err)
+
+		err = bdb.Read(func(tx bolted.ReadTx) error {
+			require.Equal(t, []string{"bob@example.com"}, collectKeys(tx.Iterate(customers)))
+			require.Equal(t, uint64(1), tx.GetSizeOf(customers))
+			return nil
+		})
+		require.NoError(t, err)
+	})
+}
@@ -0,0 +1,404 @@
+package bolted
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/draganm/bolted/dbpath"
+)
+
+// Usage of subtrees limited by quotas is stored in a bucket next to the root bucket,
+// keyed by the stored keys of the subtree's map, and updated by every transaction changing the subtree.
+// Once stored, usage is kept up to date even if the quota is not configured anymore.
+const quotaBucketName = "bolted:quota"
+
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+func IsQuotaExceeded(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	return errors.Is(err, ErrQuotaExceeded)
+}
+
+// Quota limits the subtree of every map matched by Matcher. Zero limits are not enforced.
+type Quota struct {
+	Matcher dbpath.Matcher
+	// MaxBytes limits the total size of values in the subtree.
+	MaxBytes uint64
+	// MaxEntries limits the number of values and maps in the subtree.
+	MaxEntries uint64
+	// MaxValueSize limits the size of every value put into the subtree.
+	MaxValueSize uint64
+}
+
+// QuotaUsage is the usage of a subtree. Sizes of values are their sizes before compression and encryption.
+type QuotaUsage struct {
+	Bytes   uint64
+	Entries uint64
+}
+
+// quotaDelta is the change of usage of all subtrees containing a changed path.
+type quotaDelta struct {
+	bytes   int64
+	entries int64
+	// valueSize is the size of the put value
+	valueSize uint64
+}
+
+func addDelta(v uint64, d int64) uint64 {
+	if d < 0 && uint64(-d) > v {
+		return 0
+	}
+	return uint64(int64(v) + d)
+}
+
+func encodeQuotaUsage(u QuotaUsage) []byte {
+	v := make([]byte, 16)
+	binary.BigEndian.PutUint64(v, u.Bytes)
+	binary.BigEndian.PutUint64(v[8:], u.Entries)
+	return v
+}
+
+func decodeQuotaUsage(v []byte) (QuotaUsage, error) {
+	if len(v) != 16 {
+		return QuotaUsage{}, fmt.Errorf("invalid quota usage record of %d bytes", len(v))
+	}
+	return QuotaUsage{
+		Bytes:   binary.BigEndian.Uint64(v),
+		Entries: binary.BigEndian.Uint64(v[8:]),
+	}, nil
+}
+
+// appendQuotaKey appends the length prefixed stored key to the key of the parent,
+// so keys of all maps in a subtree start with the key of the subtree's map.
+func appendQuotaKey(parentKey, storedKey []byte) []byte {
+	var l [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(l[:], uint64(len(storedKey)))
+	k := make([]byte, 0, len(parentKey)+n+len(storedKey))
+	k = append(k, parentKey...)
+	k = append(k, l[:n]...)
+	return append(k, storedKey...)
+}
+
+// rootQuotaKey is the key of the root map, keys can't be empty.
+var rootQuotaKey = []byte{'/'}
+
+func (w *writeTx) quotaKey(path dbpath.Path) []byte {
+	k := rootQuotaKey
+	for i := range path {
+		k = appendQuotaKey(k, w.storedKey(path[:i+1]))
+	}
+	return k
+}
+
+// tracksQuotas returns true if quotas are configured or usage of some subtree is stored.
+func (w *writeTx) tracksQuotas() bool {
+	return len(w.quotas) > 0 || w.btx.Bucket([]byte(quotaBucketName)) != nil
+}
+
+func (w *writeTx) quotasOf(path dbpath.Path) []Quota {
+	var quotas []Quota
+	for _, q := range w.quotas {
+		if q.Matcher.Matches(path) {
+			quotas = append(quotas, q)
+		}
+	}
+	return quotas
+}
+
+// chargeQuota applies the delta to the usage of all limited subtrees containing path,
+// failing with ErrQuotaExceeded if a growing usage exceeds a limit.
+// It must be called before path is changed, since missing usage is computed from the stored subtree.
+func (w *writeTx) chargeQuota(path dbpath.Path, d quotaDelta) error {
+	records := w.btx.Bucket([]byte(quotaBucketName))
+
+	key := rootQuotaKey
+
+	for i := 0; i < len(path); i++ {
+		root := path[:i]
+		if i > 0 {
+			key = appendQuotaKey(key, w.storedKey(root))
+		}
+
+		quotas := w.quotasOf(root)
+
+		var record []byte
+		if records != nil {
+			record = records.Get(key)
+		}
+
+		if len(quotas) == 0 && record == nil {
+			continue
+		}
+
+		for _, q := range quotas {
+			if q.MaxValueSize > 0 && d.valueSize > q.MaxValueSize {
+				return fmt.Errorf("%w: value of %d bytes is larger than %d bytes allowed in %s", ErrQuotaExceeded, d.valueSize, q.MaxValueSize, root.String())
+			}
+		}
+
+		var usage QuotaUsage
+		var err error
+		if record == nil {
+			usage, err = w.subtreeUsage(root)
+		} else {
+			usage, err = decodeQuotaUsage(record)
+		}
+		if err != nil {
+			return err
+		}
+
+		usage.Bytes = addDelta(usage.Bytes, d.bytes)
+		usage.Entries = addDelta(usage.Entries, d.entries)
+
+		for _, q := range quotas {
+			if d.bytes > 0 && q.MaxBytes > 0 && usage.Bytes > q.MaxBytes {
+				return fmt.Errorf("%w: %s would contain %d bytes, %d bytes are allowed", ErrQuotaExceeded, root.String(), usage.Bytes, q.MaxBytes)
+			}
+			if d.entries > 0 && q.MaxEntries > 0 && usage.Entries > q.MaxEntries {
+				return fmt.Errorf("%w: %s would contain %d entries, %d entries are allowed", ErrQuotaExceeded, root.String(), usage.Entries, q.MaxEntries)
+			}
+		}
+
+		if records == nil {
+			records, err = w.btx.CreateBucket([]byte(quotaBucketName))
+			if err != nil {
+				return err
+			}
+		}
+
+		err = records.Put(key, encodeQuotaUsage(usage))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// storedValueSize returns the size of the value or blob stored under key of the bucket.
+func (w *writeTx) storedValueSize(bucket storageBucket, path dbpath.Path, key []byte) (size uint64, exists bool, err error) {
+	v := bucket.Get(key)
+	if v != nil {
+		size, err = w.decodedSize(path, v)
+		return size, true, err
+	}
+
+	b := bucket.Bucket(key)
+	if isBlob(b) {
+		return blobSize(b), true, nil
+	}
+
+	return 0, false, nil
+}
+
+// chargeQuotaForPut charges quotas for replacing the value stored under key of the bucket with a value of the size.
+func (w *writeTx) chargeQuotaForPut(bucket storageBucket, path dbpath.Path, key []byte, size uint64) error {
+	if !w.tracksQuotas() {
+		return nil
+	}
+
+	oldSize, exists, err := w.storedValueSize(bucket, path, key)
+	if err != nil {
+		return err
+	}
+
+	d := quotaDelta{bytes: int64(size), entries: 1, valueSize: size}
+	if exists {
+		d.bytes -= int64(oldSize)
+		d.entries = 0
+	}
+
+	return w.chargeQuota(path, d)
+}
+
+// quotaLimit returns the size of the largest value that can replace the value stored under key of the bucket
+// without exceeding quotas. It returns false if no quota limits the value.
+func (w *writeTx) quotaLimit(bucket storageBucket, path dbpath.Path, key []byte) (limit uint64, limited bool, err error) {
+	if len(w.quotas) == 0 {
+		return 0, false, nil
+	}
+
+	oldSize, _, err := w.storedValueSize(bucket, path, key)
+	if err != nil {
+		return 0, false, err
+	}
+
+	limit = math.MaxUint64
+
+	for i := 0; i < len(path); i++ {
+		root := path[:i]
+		for _, q := range w.quotasOf(root) {
+			if q.MaxValueSize > 0 {
+				limit = min(limit, q.MaxValueSize)
+				limited = true
+			}
+
+			if q.MaxBytes > 0 {
+				usage, err := w.quotaUsage(root)
+				if err != nil {
+					return 0, false, err
+				}
+
+				available := uint64(0)
+				if q.MaxBytes+oldSize > usage.Bytes {
+					available = q.MaxBytes + oldSize - usage.Bytes
+				}
+
+				limit = min(limit, available)
+				limited = true
+			}
+		}
+	}
+
+	return limit, limited, nil
+}
+
+// chargeQuotaForDelete releases quotas used by the value or map stored under key of the bucket,
+// and removes stored usage of subtrees within the deleted map.
+func (w *writeTx) chargeQuotaForDelete(bucket storageBucket, path dbpath.Path, key []byte) error {
+	if !w.tracksQuotas() {
+		return nil
+	}
+
+	size, exists, err := w.storedValueSize(bucket, path, key)
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		return w.chargeQuota(path, quotaDelta{bytes: -int64(size), entries: -1})
+	}
+
+	b := bucket.Bucket(key)
+	if b == nil {
+		return nil
+	}
+
+	usage, stored, err := w.storedQuotaUsage(path)
+	if err == nil && !stored {
+		usage, err = w.mapUsage(path, b)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	err = w.chargeQuota(path, quotaDelta{bytes: -int64(usage.Bytes), entries: -int64(usage.Entries) - 1})
+	if err != nil {
+		return err
+	}
+
+	return w.dropQuotaUsage(path)
+}
+
+// dropQuotaUsage removes stored usage of the map at path and of all maps in its subtree.
+func (w *writeTx) dropQuotaUsage(path dbpath.Path) error {
+	records := w.btx.Bucket([]byte(quotaBucketName))
+	if records == nil {
+		return nil
+	}
+
+	prefix := w.quotaKey(path)
+
+	dropped := [][]byte{}
+	c := records.Cursor()
+	for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+		dropped = append(dropped, append([]byte(nil), k...))
+	}
+
+	for _, k := range dropped {
+		err := records.Delete(k)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *writeTx) subtreeUsage(path dbpath.Path) (QuotaUsage, error) {
+	b, err := w.bucket(path)
+	if err != nil {
+		return QuotaUsage{}, err
+	}
+	return w.mapUsage(path, b)
+}
+
+// mapUsage computes usage of the map's subtree by visiting all of its values and maps.
+func (w *writeTx) mapUsage(path dbpath.Path, b storageBucket) (QuotaUsage, error) {
+	usage := QuotaUsage{}
+
+	c := b.Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		childPath := w.childPath(path, k)
+		usage.Entries++
+
+		if v != nil {
+			size, err := w.decodedSize(childPath, v)
+			if err != nil {
+				return QuotaUsage{}, err
+			}
+			usage.Bytes += size
+			continue
+		}
+
+		child := b.Bucket(k)
+		if isBlob(child) {
+			usage.Bytes += blobSize(child)
+			continue
+		}
+
+		childUsage, err := w.mapUsage(childPath, child)
+		if err != nil {
+			return QuotaUsage{}, err
+		}
+		usage.Bytes += childUsage.Bytes
+		usage.Entries += childUsage.Entries
+	}
+
+	return usage, nil
+}
+
+// storedQuotaUsage returns the stored usage of the map at path. It returns false if the usage is not stored.
+func (w *writeTx) storedQuotaUsage(path dbpath.Path) (QuotaUsage, bool, error) {
+	records := w.btx.Bucket([]byte(quotaBucketName))
+	if records == nil {
+		return QuotaUsage{}, false, nil
+	}
+
+	record := records.Get(w.quotaKey(path))
+	if record == nil {
+		return QuotaUsage{}, false, nil
+	}
+
+	usage, err := decodeQuotaUsage(record)
+	return usage, true, err
+}
+
+// quotaUsage returns the stored usage of the map at path, or computes it if it is not stored.
+func (w *writeTx) quotaUsage(path dbpath.Path) (QuotaUsage, error) {
+	usage, stored, err := w.storedQuotaUsage(path)
+	if err != nil || stored {
+		return usage, err
+	}
+	return w.subtreeUsage(path)
+}
+
+// QuotaUsage returns the total size of values and the number of entries in the subtree of the map at path.
+func (b *LocalDB) QuotaUsage(ctx context.Context, path dbpath.Path) (usage QuotaUsage, err error) {
+	err = b.ReadWithContext(ctx, func(tx ReadTx) error {
+		usage, err = tx.(*writeTx).quotaUsage(path)
+		if err != nil {
+			return fmt.Errorf("while getting quota usage of %s: %w", path.String(), err)
+		}
+		return nil
+	})
+	return usage, err
+}
@@ -0,0 +1,190 @@
+package bolted_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/draganm/bolted"
+	"github.com/draganm/bolted/dbpath"
+	"github.com/stretchr/testify/require"
+)
+
+// endlessReader returns zeros without ever reaching EOF.
+type endlessReader struct{}
+
+func (endlessReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+func TestQuotas(t *testing.T) {
+	db, cleanup := openEmptyDatabase(t, bolted.Options{
+		Quotas: []bolted.Quota{
+			{Matcher: dbpath.MustParseMatcher("tenants/*"), MaxBytes: 10, MaxEntries: 4, MaxValueSize: 6},
+		},
+	})
+	defer cleanup()
+
+	bdb := db.(*bolted.LocalDB)
+
+	tenant := dbpath.ToPath("tenants", "a")
+
+	usage := func(t *testing.T) bolted.QuotaUsage {
+		u, err := bdb.QuotaUsage(context.Background(), tenant)
+		require.NoError(t, err)
+		return u
+	}
+
+	write := func(fn func(tx bolted.WriteTx)) error {
+		return bdb.Write(func(tx bolted.WriteTx) error {
+			fn(tx)
+			return nil
+		})
+	}
+
+	err := write(func(tx bolted.WriteTx) {
+		tx.CreateMap(dbpath.ToPath("tenants"))
+		tx.CreateMap(tenant)
+	})
+	require.NoError(t, err)
+
+	t.Run("usage is tracked", func(t *testing.T) {
+		err := write(func(tx bolted.WriteTx) {
+			tx.Put(tenant.Append("v1"), []byte("abc"))
+			tx.CreateMap(tenant.Append("m"))
+			tx.PutMany(tenant.Append("m"), []bolted.KeyValue{{Key: "v2", Value: []byte("de")}})
+		})
+		require.NoError(t, err)
+		require.Equal(t, bolted.QuotaUsage{Bytes: 5, Entries: 3}, usage(t))
+	})
+
+	t.Run("replacing a value charges the difference", func(t *testing.T) {
+		err := write(func(tx bolted.WriteTx) {
+			tx.Put(tenant.Append("v1"), []byte("abcdef"))
+		})
+		require.NoError(t, err)
+		require.Equal(t, bolted.QuotaUsage{Bytes: 8, Entries: 3}, usage(t))
+	})
+
+	t.Run("exceeding quotas", func(t *testing.T) {
+		cases := []struct {
+			name string
+			fn   func(tx bolted.WriteTx)
+		}{
+			{
+				name: "value size",
+				fn:   func(tx bolted.WriteTx) { tx.Put(tenant.Append("m", "v2"), []byte("1234567")) },
+			},
+			{
+				name: "total bytes",
+				fn:   func(tx bolted.WriteTx) { tx.Put(tenant.Append("v3"), []byte("xyz")) },
+			},
+			{
+				name: "total bytes of blob",
+				fn:   func(tx bolted.WriteTx) { tx.PutReader(tenant.Append("v3"), bytes.NewReader([]byte("xyz"))) },
+			},
+			{
+				name: "endless blob",
+				fn:   func(tx bolted.WriteTx) { tx.PutReader(tenant.Append("v3"), endlessReader{}) },
+			},
+			{
+				name: "entries",
+				fn: func(tx bolted.WriteTx) {
+					tx.CreateMap(tenant.Append("m2"))
+					tx.CreateMap(tenant.Append("m3"))
+				},
+			},
+		}
+
+		for _, c := range cases {
+			t.Run(c.name, func(t *testing.T) {
+				err := write(c.fn)
+				require.True(t, bolted.IsQuotaExceeded(err), "expected quota exceeded, got %v", err)
+				require.Equal(t, bolted.QuotaUsage{Bytes: 8, Entries: 3}, usage(t))
+			})
+		}
+	})
+
+	t.Run("other subtrees are not limited", func(t *testing.T) {
+		err := write(func(tx bolted.WriteTx) {
+			tx.Put(dbpath.ToPath("tenants", "big"), []byte("0123456789abcdef"))
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("deletion releases usage", func(t *testing.T) {
+		err := write(func(tx bolted.WriteTx) {
+			tx.Delete(tenant.Append("m"))
+			tx.PutReader(tenant.Append("v3"), bytes.NewReader([]byte("xyz")))
+		})
+		require.NoError(t, err)
+		require.Equal(t, bolted.QuotaUsage{Bytes: 9, Entries: 2}, usage(t))
+
+		err = write(func(tx bolted.WriteTx) {
+			tx.DeleteMany(tenant, []string{"v1", "v3"})
+		})
+		require.NoError(t, err)
+		require.Equal(t, bolted.QuotaUsage{}, usage(t))
+	})
+
+	t.Run("usage of recreated subtree starts empty", func(t *testing.T) {
+		err := write(func(tx bolted.WriteTx) {
+			tx.Put(tenant.Append("v1"), []byte("abc"))
+			tx.Delete(tenant)
+			tx.CreateMap(tenant)
+			tx.Put(tenant.Append("v2"), []byte("de"))
+		})
+		require.NoError(t, err)
+		require.Equal(t, bolted.QuotaUsage{Bytes: 2, Entries: 1}, usage(t))
+	})
+}
+
+func TestQuotaOfExistingData(t *testing.T) {
+	td, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(td)
+
+	dbFile := filepath.Join(td, "db")
+
+	db, err := bolted.Open(dbFile, 0660, bolted.Options{})
+	require.NoError(t, err)
+
+	err = db.Write(func(tx bolted.WriteTx) error {
+		tx.CreateMap(dbpath.ToPath("data"))
+		tx.Put(dbpath.ToPath("data", "v1"), []byte("0123456789"))
+		return nil
+	})
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	db, err = bolted.Open(dbFile, 0660, bolted.Options{
+		Quotas: []bolted.Quota{
+			{Matcher: dbpath.MustParseMatcher("data"), MaxBytes: 12},
+		},
+		Compressed: dbpath.MustParseMatcher("data/*"),
+	})
+	require.NoError(t, err)
+	defer db.Close()
+
+	err = db.Write(func(tx bolted.WriteTx) error {
+		tx.Put(dbpath.ToPath("data", "v2"), []byte("abc"))
+		return nil
+	})
+	require.True(t, bolted.IsQuotaExceeded(err), "expected quota exceeded, got %v", err)
+
+	err = db.Write(func(tx bolted.WriteTx) error {
+		tx.Put(dbpath.ToPath("data", "v2"), []byte("ab"))
+		return nil
+	})
+	require.NoError(t, err)
+
+	usage, err := db.QuotaUsage(context.Background(), dbpath.ToPath("data"))
+	require.NoError(t, err)
+	require.Equal(t, bolted.QuotaUsage{Bytes: 12, Entries: 2}, usage)
+}
@@ -0,0 +1,74 @@
+package bolted
+
+import (
+	"context"
+	"errors"
+
+	"github.com/draganm/bolted/dbpath"
+)
+
+var ErrReadOnly = errors.New("database is read-only")
+
+var errNoCommitChain = errors.New("database has no commit chain")
+
+func IsReadOnly(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	return errors.Is(err, ErrReadOnly)
+}
+
+// OpenReadOnly opens an existing database file for reading.
+// The file is not modified, and write transactions fail with ErrReadOnly.
+func OpenReadOnly(path string, options Options) (Database, error) {
+	options.ReadOnly = true
+	db, err := Open(path, 0, options)
+	if err != nil {
+		return nil, err
+	}
+	return ReadOnly(db), nil
+}
+
+// ReadOnly returns a Database whose write transactions fail with ErrReadOnly without being started.
+func ReadOnly(db Database) Database {
+	_, isReadOnly := db.(readOnlyDB)
+	if isReadOnly {
+		return db
+	}
+	return readOnlyDB{db}
+}
+
+type readOnlyDB struct {
+	Database
+}
+
+func (r readOnlyDB) Write(fn func(tx WriteTx) error) error {
+	return ErrReadOnly
+}
+
+func (r readOnlyDB) WriteWithContext(ctx context.Context, fn func(tx WriteTx) error) error {
+	return ErrReadOnly
+}
+
+func (r readOnlyDB) Sub(prefix dbpath.Path) Database {
+	return readOnlyDB{r.Database.Sub(prefix)}
+}
+
+// CommitHash returns the hash of the commit of the wrapped database, see LocalDB.CommitHash.
+func (r readOnlyDB) CommitHash(txID uint64) ([]byte, error) {
+	chain, isChain := r.Database.(CommitChain)
+	if !isChain {
+		return nil, errNoCommitChain
+	}
+	return chain.CommitHash(txID)
+}
+
+// VerifyCommitChain verifies the commit chain of the wrapped database, see LocalDB.VerifyCommitChain.
+func (r readOnlyDB) VerifyCommitChain(ctx context.Context) (CommitChainHead, error) {
+	chain, isChain := r.Database.(CommitChain)
+	if !isChain {
+		return CommitChainHead{}, errNoCommitChain
+	}
+	return chain.VerifyCommitChain(ctx)
+}
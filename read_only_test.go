@@ -0,0 +1,104 @@
+package bolted_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/draganm/bolted"
+	"github.com/draganm/bolted/dbpath"
+	"github.com/stretchr/testify/require"
+	"go.etcd.io/bbolt"
+)
+
+func TestOpenReadOnly(t *testing.T) {
+	td, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(td)
+
+	t.Run("existing database", func(t *testing.T) {
+		dbFile := filepath.Join(td, "db")
+
+		bdb, err := bolted.Open(dbFile, 0660, bolted.Options{})
+		require.NoError(t, err)
+		err = bdb.Write(func(tx bolted.WriteTx) error {
+			tx.Put(dbpath.ToPath("v"), []byte("value"))
+			return nil
+		})
+		require.NoError(t, err)
+		require.NoError(t, bdb.Close())
+
+		db, err := bolted.OpenReadOnly(dbFile, bolted.Options{})
+		require.NoError(t, err)
+		defer db.Close()
+
+		err = db.Read(func(tx bolted.ReadTx) error {
+			require.Equal(t, []byte("value"), tx.Get(dbpath.ToPath("v")))
+			return nil
+		})
+		require.NoError(t, err)
+
+		err = db.Write(func(tx bolted.WriteTx) error {
+			t.Fatal("write transaction should not be started")
+			return nil
+		})
+		require.True(t, bolted.IsReadOnly(err))
+	})
+
+	t.Run("database without root bucket", func(t *testing.T) {
+		dbFile := filepath.Join(td, "empty")
+
+		bdb, err := bbolt.Open(dbFile, 0660, nil)
+		require.NoError(t, err)
+		require.NoError(t, bdb.Close())
+
+		db, err := bolted.OpenReadOnly(dbFile, bolted.Options{})
+		require.NoError(t, err)
+		defer db.Close()
+
+		err = db.Read(func(tx bolted.ReadTx) error {
+			tx.Iterate(dbpath.NilPath)
+			return nil
+		})
+		require.Error(t, err)
+	})
+}
+
+func TestReadOnly(t *testing.T) {
+	db, cleanup := openMemoryDatabase(t, bolted.Options{})
+	defer cleanup()
+
+	err := db.Write(func(tx bolted.WriteTx) error {
+		tx.CreateMap(dbpath.ToPath("m"))
+		return nil
+	})
+	require.NoError(t, err)
+
+	rdb := bolted.ReadOnly(db)
+
+	cases := []struct {
+		name string
+		db   bolted.Database
+	}{
+		{name: "wrapped database", db: rdb},
+		{name: "wrapped twice", db: bolted.ReadOnly(rdb)},
+		{name: "sub database", db: rdb.Sub(dbpath.ToPath("m"))},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.db.Write(func(tx bolted.WriteTx) error {
+				tx.Put(dbpath.ToPath("v"), []byte("value"))
+				return nil
+			})
+			require.True(t, bolted.IsReadOnly(err))
+
+			err = tc.db.Read(func(tx bolted.ReadTx) error {
+				require.False(t, tx.Exists(dbpath.ToPath("v")))
+				return nil
+			})
+			require.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package bolted
+
+import (
+	"io"
+
+	"go.etcd.io/bbolt"
+)
+
+// storageTx, storageBucket and storageCursor abstract the storage transactions operate on,
+// so the same transaction implementation works on top of bbolt and in memory.
+// Implementations must behave like bbolt, including the returned errors.
+type storageTx interface {
+	Bucket(name []byte) storageBucket
+	CreateBucket(name []byte) (storageBucket, error)
+	ID() int
+	Size() int64
+	WriteTo(w io.Writer) (int64, error)
+}
+
+type storageBucket interface {
+	Get(key []byte) []byte
+	Put(key, value []byte) error
+	Delete(key []byte) error
+	Bucket(name []byte) storageBucket
+	CreateBucket(name []byte) (storageBucket, error)
+	DeleteBucket(name []byte) error
+	Cursor() storageCursor
+	Sequence() uint64
+	SetSequence(v uint64) error
+	NextSequence() (uint64, error)
+	SetFillPercent(fillPercent float64)
+}
+
+type storageCursor interface {
+	Bucket() storageBucket
+	First() (key, value []byte)
+	Last() (key, value []byte)
+	Next() (key, value []byte)
+	Prev() (key, value []byte)
+	Seek(seek []byte) (key, value []byte)
+}
+
+type boltTx struct {
+	*bbolt.Tx
+}
+
+func (t boltTx) Bucket(name []byte) storageBucket {
+	return wrapBoltBucket(t.Tx.Bucket(name))
+}
+
+func (t boltTx) CreateBucket(name []byte) (storageBucket, error) {
+	b, err := t.Tx.CreateBucket(name)
+	if err != nil {
+		return nil, err
+	}
+	return boltBucket{b}, nil
+}
+
+type boltBucket struct {
+	b *bbolt.Bucket
+}
+
+// wrapBoltBucket makes sure that a missing bucket is a nil storageBucket.
+func wrapBoltBucket(b *bbolt.Bucket) storageBucket {
+	if b == nil {
+		return nil
+	}
+	return boltBucket{b}
+}
+
+func (b boltBucket) Get(key []byte) []byte {
+	return b.b.Get(key)
+}
+
+func (b boltBucket) Put(key, value []byte) error {
+	return b.b.Put(key, value)
+}
+
+func (b boltBucket) Delete(key []byte) error {
+	return b.b.Delete(key)
+}
+
+func (b boltBucket) Bucket(name []byte) storageBucket {
+	return wrapBoltBucket(b.b.Bucket(name))
+}
+
+func (b boltBucket) CreateBucket(name []byte) (storageBucket, error) {
+	c, err := b.b.CreateBucket(name)
+	if err != nil {
+		return nil, err
+	}
+	return boltBucket{c}, nil
+}
+
+func (b boltBucket) DeleteBucket(name []byte) error {
+	return b.b.DeleteBucket(name)
+}
+
+func (b boltBucket) Cursor() storageCursor {
+	return boltCursor{b.b.Cursor()}
+}
+
+func (b boltBucket) Sequence() uint64 {
+	return b.b.Sequence()
+}
+
+func (b boltBucket) SetSequence(v uint64) error {
+	return b.b.SetSequence(v)
+}
+
+func (b boltBucket) NextSequence() (uint64, error) {
+	return b.b.NextSequence()
+}
+
+func (b boltBucket) SetFillPercent(fillPercent float64) {
+	b.b.FillPercent = fillPercent
+}
+
+type boltCursor struct {
+	c *bbolt.Cursor
+}
+
+func (c boltCursor) Bucket() storageBucket {
+	return wrapBoltBucket(c.c.Bucket())
+}
+
+func (c boltCursor) First() ([]byte, []byte) {
+	return c.c.First()
+}
+
+func (c boltCursor) Last() ([]byte, []byte) {
+	return c.c.Last()
+}
+
+func (c boltCursor) Next() ([]byte, []byte) {
+	return c.c.Next()
+}
+
+func (c boltCursor) Prev() ([]byte, []byte) {
+	return c.c.Prev()
+}
+
+func (c boltCursor) Seek(seek []byte) ([]byte, []byte) {
+	return c.c.Seek(seek)
+}
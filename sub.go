@@ -0,0 +1,266 @@
+package bolted
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/draganm/bolted/dbpath"
+	"go.etcd.io/bbolt"
+)
+
+var errDumpOfSubDatabase = errors.New("sub database can't be dumped")
+
+// subDB is a Database whose paths are relative to a prefix in the underlying database.
+// The map at prefix must exist.
+type subDB struct {
+	db     Database
+	prefix dbpath.Path
+}
+
+func newSubDB(db Database, prefix dbpath.Path) Database {
+	return &subDB{
+		db:     db,
+		prefix: prefix.Append(),
+	}
+}
+
+func (b *LocalDB) Sub(prefix dbpath.Path) Database {
+	return newSubDB(b, prefix)
+}
+
+func (m *MemoryDB) Sub(prefix dbpath.Path) Database {
+	return newSubDB(m, prefix)
+}
+
+func (d *interceptedDB) Sub(prefix dbpath.Path) Database {
+	return newSubDB(d, prefix)
+}
+
+func (s *subDB) Sub(prefix dbpath.Path) Database {
+	return newSubDB(s.db, s.prefix.Append(prefix...))
+}
+
+func (s *subDB) Read(fn func(tx ReadTx) error) error {
+	return s.ReadWithContext(context.Background(), fn)
+}
+
+func (s *subDB) ReadWithContext(ctx context.Context, fn func(tx ReadTx) error) error {
+	return s.db.ReadWithContext(ctx, func(tx ReadTx) error {
+		return fn(&subTx{ReadTx: tx, wtx: notWritableTx{tx}, prefix: s.prefix})
+	})
+}
+
+func (s *subDB) Write(fn func(tx WriteTx) error) error {
+	return s.WriteWithContext(context.Background(), fn)
+}
+
+func (s *subDB) WriteWithContext(ctx context.Context, fn func(tx WriteTx) error) error {
+	return s.db.WriteWithContext(ctx, func(tx WriteTx) error {
+		return fn(&subTx{ReadTx: tx, wtx: tx, prefix: s.prefix})
+	})
+}
+
+// Observe observes paths relative to the prefix and reports changes relative to the prefix.
+// Deletion of the prefix or one of its parents is reported as deletion of the root.
+func (s *subDB) Observe(ctx context.Context, m dbpath.Matcher) <-chan ObservedChanges {
+	changes := s.db.Observe(ctx, append(s.prefix.ToMatcher(), m...))
+	relativeChanges := make(chan ObservedChanges)
+
+	go func() {
+		defer close(relativeChanges)
+		first := true
+		for ch := range changes {
+			relative := ObservedChanges{}
+			for _, c := range ch {
+				switch {
+				case s.prefix.IsPrefixOf(c.Path):
+					relative = append(relative, ObservedChange{Path: c.Path[len(s.prefix):].Append(), Type: c.Type})
+				case c.Type == ChangeTypeDeleted && c.Path.IsPrefixOf(s.prefix):
+					relative = relative.Update(dbpath.NilPath, ChangeTypeDeleted)
+				}
+			}
+
+			// the initial empty changes are always sent
+			if len(relative) == 0 && !first {
+				continue
+			}
+			first = false
+
+			select {
+			case relativeChanges <- relative:
+			case <-ctx.Done():
+				// drain changes until the underlying channel is closed
+				for range changes {
+				}
+				return
+			}
+		}
+	}()
+
+	return relativeChanges
+}
+
+// Close does nothing, the underlying database has to be closed by its owner.
+func (s *subDB) Close() error {
+	return nil
+}
+
+func (s *subDB) Stats() (*bbolt.Stats, error) {
+	return s.db.Stats()
+}
+
+// subTx translates relative paths of a sub database to absolute paths.
+// The embedded ReadTx provides ID, GetDBFileSize and Context.
+type subTx struct {
+	ReadTx
+	// wtx is notWritableTx for read transactions
+	wtx    WriteTx
+	prefix dbpath.Path
+}
+
+func (t *subTx) abs(path dbpath.Path) dbpath.Path {
+	return t.prefix.Append(path...)
+}
+
+// absNonRoot returns the absolute path, or the root of the database if the path is the root of the sub database,
+// so methods not supporting the root fail in the same way as in the underlying database.
+func (t *subTx) absNonRoot(path dbpath.Path) dbpath.Path {
+	if len(path) == 0 {
+		return dbpath.NilPath
+	}
+	return t.abs(path)
+}
+
+func (t *subTx) rel(path dbpath.Path) dbpath.Path {
+	return path[len(t.prefix):].Append()
+}
+
+func (t *subTx) absMatcher(m dbpath.Matcher) dbpath.Matcher {
+	if m == nil {
+		return nil
+	}
+	return append(t.prefix.ToMatcher(), m...)
+}
+
+func (t *subTx) CreateMap(path dbpath.Path) {
+	t.wtx.CreateMap(t.absNonRoot(path))
+}
+
+func (t *subTx) Delete(path dbpath.Path) {
+	t.wtx.Delete(t.absNonRoot(path))
+}
+
+func (t *subTx) Put(path dbpath.Path, value []byte) {
+	t.wtx.Put(t.absNonRoot(path), value)
+}
+
+func (t *subTx) PutMany(parent dbpath.Path, kvs []KeyValue) {
+	t.wtx.PutMany(t.abs(parent), kvs)
+}
+
+func (t *subTx) PutReader(path dbpath.Path, r io.Reader) {
+	t.wtx.PutReader(t.absNonRoot(path), r)
+}
+
+func (t *subTx) DeleteMany(parent dbpath.Path, keys []string) {
+	t.wtx.DeleteMany(t.abs(parent), keys)
+}
+
+func (t *subTx) SetFillPercent(fillPercent float64) {
+	t.wtx.SetFillPercent(fillPercent)
+}
+
+func (t *subTx) Get(path dbpath.Path) []byte {
+	return t.ReadTx.Get(t.absNonRoot(path))
+}
+
+func (t *subTx) View(path dbpath.Path, fn func(v []byte) error) error {
+	return t.ReadTx.View(t.absNonRoot(path), fn)
+}
+
+func (t *subTx) GetInto(path dbpath.Path, buf []byte) []byte {
+	return t.ReadTx.GetInto(t.absNonRoot(path), buf)
+}
+
+func (t *subTx) GetMany(parent dbpath.Path, keys []string) [][]byte {
+	return t.ReadTx.GetMany(t.abs(parent), keys)
+}
+
+func (t *subTx) OpenReader(path dbpath.Path) io.ReadSeeker {
+	return t.ReadTx.OpenReader(t.absNonRoot(path))
+}
+
+func (t *subTx) Iterate(path dbpath.Path) Iterator {
+	return t.ReadTx.Iterate(t.abs(path))
+}
+
+func (t *subTx) IterateRange(path dbpath.Path, from, to Bound) Iterator {
+	return t.ReadTx.IterateRange(t.abs(path), from, to)
+}
+
+func (t *subTx) IterateRangeReverse(path dbpath.Path, from, to Bound) Iterator {
+	return t.ReadTx.IterateRangeReverse(t.abs(path), from, to)
+}
+
+func (t *subTx) IteratePrefix(path dbpath.Path, prefix string) Iterator {
+	return t.ReadTx.IteratePrefix(t.abs(path), prefix)
+}
+
+func (t *subTx) IteratePrefixReverse(path dbpath.Path, prefix string) Iterator {
+	return t.ReadTx.IteratePrefixReverse(t.abs(path), prefix)
+}
+
+func (t *subTx) Page(path dbpath.Path, token string, limit int) PageResult {
+	return t.ReadTx.Page(t.abs(path), token, limit)
+}
+
+func (t *subTx) PageReverse(path dbpath.Path, token string, limit int) PageResult {
+	return t.ReadTx.PageReverse(t.abs(path), token, limit)
+}
+
+func (t *subTx) relWalkFunc(fn WalkFunc) WalkFunc {
+	return func(path dbpath.Path, isMap bool, value []byte) error {
+		return fn(t.rel(path), isMap, value)
+	}
+}
+
+func (t *subTx) Walk(root dbpath.Path, fn WalkFunc) error {
+	return t.ReadTx.Walk(t.abs(root), t.relWalkFunc(fn))
+}
+
+func (t *subTx) WalkWithOptions(root dbpath.Path, opts WalkOptions, fn WalkFunc) error {
+	opts.Matcher = t.absMatcher(opts.Matcher)
+	return t.ReadTx.WalkWithOptions(t.abs(root), opts, t.relWalkFunc(fn))
+}
+
+func (t *subTx) Find(m dbpath.Matcher) []dbpath.Path {
+	found := t.ReadTx.Find(t.absMatcher(m))
+	for i, p := range found {
+		found[i] = t.rel(p)
+	}
+	return found
+}
+
+func (t *subTx) FindWithFunc(m dbpath.Matcher, fn FindFunc) error {
+	return t.ReadTx.FindWithFunc(t.absMatcher(m), func(path dbpath.Path, isMap bool) error {
+		return fn(t.rel(path), isMap)
+	})
+}
+
+func (t *subTx) Exists(path dbpath.Path) bool {
+	return t.ReadTx.Exists(t.abs(path))
+}
+
+func (t *subTx) IsMap(path dbpath.Path) bool {
+	return t.ReadTx.IsMap(t.abs(path))
+}
+
+func (t *subTx) GetSizeOf(path dbpath.Path) uint64 {
+	return t.ReadTx.GetSizeOf(t.abs(path))
+}
+
+func (t *subTx) DumpDatabase(w io.Writer) int64 {
+	raiseErrorForPath(dbpath.NilPath, "DumpDatabase", errDumpOfSubDatabase)
+	return 0
+}
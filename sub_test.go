@@ -0,0 +1,116 @@
+package bolted_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/draganm/bolted"
+	"github.com/draganm/bolted/dbpath"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSub(t *testing.T) {
+	db, cleanup := openEmptyDatabase(t, bolted.Options{})
+	defer cleanup()
+
+	err := db.Write(func(tx bolted.WriteTx) error {
+		tx.CreateMap(dbpath.ToPath("modules"))
+		tx.CreateMap(dbpath.ToPath("modules", "a"))
+		tx.CreateMap(dbpath.ToPath("modules", "b"))
+		tx.Put(dbpath.ToPath("modules", "b", "secret"), []byte("b"))
+		return nil
+	})
+	require.NoError(t, err)
+
+	sub := db.Sub(dbpath.ToPath("modules", "a"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	updates := sub.Observe(ctx, dbpath.MustParseMatcher("**"))
+	require.Equal(t, bolted.ObservedChanges{}, <-updates)
+
+	t.Run("paths are relative to the prefix", func(t *testing.T) {
+		err := sub.Write(func(tx bolted.WriteTx) error {
+			tx.CreateMap(dbpath.ToPath("m"))
+			tx.Put(dbpath.ToPath("m", "v"), []byte("value"))
+			return nil
+		})
+		require.NoError(t, err)
+
+		err = db.Read(func(tx bolted.ReadTx) error {
+			require.Equal(t, []byte("value"), tx.Get(dbpath.ToPath("modules", "a", "m", "v")))
+			return nil
+		})
+		require.NoError(t, err)
+
+		err = sub.Read(func(tx bolted.ReadTx) error {
+			require.Equal(t, []byte("value"), tx.Get(dbpath.ToPath("m", "v")))
+			require.Equal(t, uint64(1), tx.GetSizeOf(dbpath.NilPath))
+			require.True(t, tx.IsMap(dbpath.NilPath))
+			require.Equal(t, []string{"m"}, collectKeys(tx.Iterate(dbpath.NilPath)))
+			require.Equal(t, []dbpath.Path{dbpath.ToPath("m", "v")}, tx.Find(dbpath.MustParseMatcher("*/v")))
+
+			walked := []dbpath.Path{}
+			err := tx.Walk(dbpath.NilPath, func(path dbpath.Path, _ bool, _ []byte) error {
+				walked = append(walked, path)
+				return nil
+			})
+			require.NoError(t, err)
+			require.Equal(t, []dbpath.Path{{}, dbpath.ToPath("m"), dbpath.ToPath("m", "v")}, walked)
+			return nil
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("changes are observed relative to the prefix", func(t *testing.T) {
+		require.Equal(t, bolted.ObservedChanges{
+			{Path: dbpath.ToPath("m"), Type: bolted.ChangeTypeMapCreated},
+			{Path: dbpath.ToPath("m", "v"), Type: bolted.ChangeTypeValueSet},
+		}, <-updates)
+	})
+
+	t.Run("prefix can't be escaped", func(t *testing.T) {
+		err := sub.Read(func(tx bolted.ReadTx) error {
+			require.False(t, tx.Exists(dbpath.ToPath("..", "b", "secret")))
+			require.Empty(t, tx.Find(dbpath.MustParseMatcher("**/secret")))
+			return nil
+		})
+		require.NoError(t, err)
+
+		err = sub.Write(func(tx bolted.WriteTx) error {
+			tx.Delete(dbpath.NilPath)
+			return nil
+		})
+		require.Error(t, err)
+	})
+
+	t.Run("nested sub database", func(t *testing.T) {
+		err := sub.Sub(dbpath.ToPath("m")).Read(func(tx bolted.ReadTx) error {
+			require.Equal(t, []byte("value"), tx.Get(dbpath.ToPath("v")))
+			return nil
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("deletion of the prefix is observed as deletion of the root", func(t *testing.T) {
+		err := db.Write(func(tx bolted.WriteTx) error {
+			tx.Put(dbpath.ToPath("modules", "b", "other"), []byte("b"))
+			tx.Delete(dbpath.ToPath("modules"))
+			return nil
+		})
+		require.NoError(t, err)
+
+		require.Equal(t, bolted.ObservedChanges{
+			{Path: dbpath.NilPath, Type: bolted.ChangeTypeDeleted},
+		}, <-updates)
+	})
+
+	t.Run("closing does not close the underlying database", func(t *testing.T) {
+		require.NoError(t, sub.Close())
+		err := db.Read(func(tx bolted.ReadTx) error {
+			return nil
+		})
+		require.NoError(t, err)
+	})
+}
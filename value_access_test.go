@@ -0,0 +1,116 @@
+package bolted_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/draganm/bolted"
+	"github.com/draganm/bolted/dbpath"
+	"github.com/stretchr/testify/require"
+)
+
+func TestViewAndGetInto(t *testing.T) {
+	bdb, cleanup := openEmptyDatabase(t, bolted.Options{})
+	defer cleanup()
+
+	err := bdb.Write(func(tx bolted.WriteTx) error {
+		tx.Put(dbpath.ToPath("a"), []byte{1, 2, 3})
+		tx.CreateMap(dbpath.ToPath("m"))
+		return nil
+	})
+	require.NoError(t, err)
+
+	t.Run("view", func(t *testing.T) {
+		err := bdb.Read(func(tx bolted.ReadTx) error {
+			return tx.View(dbpath.ToPath("a"), func(v []byte) error {
+				require.Equal(t, []byte{1, 2, 3}, v)
+				return nil
+			})
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("view returns callback error", func(t *testing.T) {
+		failed := errors.New("failed")
+		err := bdb.Read(func(tx bolted.ReadTx) error {
+			return tx.View(dbpath.ToPath("a"), func(v []byte) error {
+				return failed
+			})
+		})
+		require.ErrorIs(t, err, failed)
+	})
+
+	t.Run("view of map", func(t *testing.T) {
+		err := bdb.Read(func(tx bolted.ReadTx) error {
+			return tx.View(dbpath.ToPath("m"), func(v []byte) error {
+				return nil
+			})
+		})
+		require.Error(t, err)
+	})
+
+	t.Run("get into reuses buffer", func(t *testing.T) {
+		buf := make([]byte, 0, 16)
+		err := bdb.Read(func(tx bolted.ReadTx) error {
+			v := tx.GetInto(dbpath.ToPath("a"), buf)
+			require.Equal(t, []byte{1, 2, 3}, v)
+			require.Equal(t, &buf[:1][0], &v[0])
+			return nil
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("get into grows buffer", func(t *testing.T) {
+		err := bdb.Read(func(tx bolted.ReadTx) error {
+			v := tx.GetInto(dbpath.ToPath("a"), nil)
+			require.Equal(t, []byte{1, 2, 3}, v)
+			return nil
+		})
+		require.NoError(t, err)
+	})
+}
+
+func benchmarkLargeValue(b *testing.B, read func(tx bolted.ReadTx, p dbpath.Path)) {
+	bdb, cleanup := openEmptyDatabase(b, bolted.Options{})
+	defer cleanup()
+
+	p := dbpath.ToPath("large")
+
+	err := bdb.Write(func(tx bolted.WriteTx) error {
+		tx.Put(p, make([]byte, 4*1024*1024))
+		return nil
+	})
+	require.NoError(b, err)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err = bdb.Read(func(tx bolted.ReadTx) error {
+			read(tx, p)
+			return nil
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGetLargeValue(b *testing.B) {
+	benchmarkLargeValue(b, func(tx bolted.ReadTx, p dbpath.Path) {
+		tx.Get(p)
+	})
+}
+
+func BenchmarkGetIntoLargeValue(b *testing.B) {
+	buf := []byte{}
+	benchmarkLargeValue(b, func(tx bolted.ReadTx, p dbpath.Path) {
+		buf = tx.GetInto(p, buf)
+	})
+}
+
+func BenchmarkViewLargeValue(b *testing.B) {
+	benchmarkLargeValue(b, func(tx bolted.ReadTx, p dbpath.Path) {
+		tx.View(p, func(v []byte) error {
+			return nil
+		})
+	})
+}
@@ -0,0 +1,132 @@
+package bolted
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/draganm/bolted/dbpath"
+)
+
+// Values transformed by bolted (e.g. compressed or encrypted values and content references) are stored with a frame header,
+// so they can coexist with plain values written before the transformation was enabled.
+// Plain values starting with frameMagic are stored in a plain frame, so they can't be mistaken for transformed values.
+const frameMagic = "\x00bolted\x00"
+
+type frameKind byte
+
+const (
+	framePlain      frameKind = 0
+	frameContentRef frameKind = 1
+	frameCompressed frameKind = 2
+	frameEncrypted  frameKind = 3
+)
+
+func frame(kind frameKind, payload []byte) []byte {
+	v := make([]byte, 0, len(frameMagic)+1+len(payload))
+	v = append(v, frameMagic...)
+	v = append(v, byte(kind))
+	return append(v, payload...)
+}
+
+// plainValue wraps the value in a plain frame if it would otherwise be parsed as a frame.
+func plainValue(v []byte) []byte {
+	if bytes.HasPrefix(v, []byte(frameMagic)) {
+		return frame(framePlain, v)
+	}
+	return v
+}
+
+func parseFrame(v []byte) (frameKind, []byte, bool) {
+	if len(v) <= len(frameMagic) || !bytes.HasPrefix(v, []byte(frameMagic)) {
+		return 0, nil, false
+	}
+	return frameKind(v[len(frameMagic)]), v[len(frameMagic)+1:], true
+}
+
+// decodeValue returns the logical value of a stored value, undoing all transformations.
+// The result is not copied if no transformation requires it.
+func (w *writeTx) decodeValue(path dbpath.Path, v []byte) ([]byte, error) {
+	for {
+		kind, payload, framed := parseFrame(v)
+		if !framed {
+			return v, nil
+		}
+
+		var err error
+
+		switch kind {
+		case framePlain:
+			return payload, nil
+		case frameContentRef:
+			v, err = w.loadContent(payload)
+		case frameCompressed:
+			v, err = decompressValue(payload)
+		case frameEncrypted:
+			v, err = w.encryption.decrypt(path, payload)
+		default:
+			err = fmt.Errorf("unknown value frame kind %d", kind)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// decodedSize returns the size of the logical value of a stored value,
+// decoding it only as far as needed.
+func (w *writeTx) decodedSize(path dbpath.Path, v []byte) (uint64, error) {
+	for {
+		kind, payload, framed := parseFrame(v)
+		if !framed {
+			return uint64(len(v)), nil
+		}
+
+		var err error
+
+		switch kind {
+		case framePlain:
+			return uint64(len(payload)), nil
+		case frameContentRef:
+			v, err = w.loadContent(payload)
+		case frameCompressed:
+			return compressedSize(payload)
+		case frameEncrypted:
+			v, err = w.encryption.decrypt(path, payload)
+		default:
+			err = fmt.Errorf("unknown value frame kind %d", kind)
+		}
+
+		if err != nil {
+			return 0, err
+		}
+	}
+}
+
+// encodeValue transforms the value before it is stored under the path.
+func (w *writeTx) encodeValue(path dbpath.Path, value []byte) ([]byte, error) {
+	var err error
+
+	if w.compressed != nil && w.compressed.Matches(path) {
+		value, err = compressValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("while compressing value: %w", err)
+		}
+	} else {
+		value = plainValue(value)
+	}
+
+	if w.encrypts(path) {
+		value, err = w.encryption.encrypt(path, value)
+		if err != nil {
+			return nil, fmt.Errorf("while encrypting value: %w", err)
+		}
+	}
+
+	// encrypted values differ on every write, so content addressing them doesn't deduplicate
+	if w.contentAddressed != nil && w.contentAddressed.Matches(path) {
+		return w.storeContent(value)
+	}
+
+	return value, nil
+}
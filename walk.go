@@ -0,0 +1,189 @@
+package bolted
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/draganm/bolted/dbpath"
+)
+
+// SkipMap can be returned by a WalkFunc visiting a map in pre-order to skip the map's children.
+// It is ignored in all other cases.
+var SkipMap = errors.New("skip this map")
+
+// WalkFunc is called for every visited path. value is nil for maps.
+type WalkFunc func(path dbpath.Path, isMap bool, value []byte) error
+
+type WalkOptions struct {
+	// PostOrder visits children of a map before the map itself.
+	PostOrder bool
+	// MaxDepth limits depth of visited paths relative to the root, 0 means no limit.
+	MaxDepth int
+	// Matcher limits calls of WalkFunc to matching paths, all maps are still traversed.
+	Matcher dbpath.Matcher
+}
+
+func (w *writeTx) Walk(root dbpath.Path, fn WalkFunc) error {
+	w.checkForCancelledContext()
+	bucket, value, err := w.resolveWalkRoot(root)
+	if err != nil {
+		raiseErrorForPath(root, "Walk", err)
+	}
+	return w.walk(root, bucket, value, WalkOptions{}, fn)
+}
+
+func (w *writeTx) WalkWithOptions(root dbpath.Path, opts WalkOptions, fn WalkFunc) error {
+	w.checkForCancelledContext()
+	bucket, value, err := w.resolveWalkRoot(root)
+	if err != nil {
+		raiseErrorForPath(root, "WalkWithOptions", err)
+	}
+	return w.walk(root, bucket, value, opts, fn)
+}
+
+// resolveWalkRoot returns the bucket of the root map or blob, or the stored value if root is not a map.
+func (w *writeTx) resolveWalkRoot(root dbpath.Path) (storageBucket, []byte, error) {
+	if len(root) == 0 {
+		bucket, err := w.bucket(root)
+		return bucket, nil, err
+	}
+
+	bucket, err := w.bucket(root[:len(root)-1])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	last := w.storedKey(root)
+
+	v := bucket.Get(last)
+	if v != nil {
+		return nil, v, nil
+	}
+
+	bucket = bucket.Bucket(last)
+	if bucket == nil {
+		return nil, nil, ErrNotFound
+	}
+
+	return bucket, nil, nil
+}
+
+func (w *writeTx) walk(root dbpath.Path, bucket storageBucket, value []byte, opts WalkOptions, fn WalkFunc) error {
+	wk := &walker{
+		opts: opts,
+		fn:   fn,
+		w:    w,
+	}
+
+	var err error
+
+	switch {
+	case bucket == nil:
+		err = wk.visitValue(root, value)
+	case isBlob(bucket):
+		err = wk.visitBlob(root, bucket)
+	default:
+		return wk.walkMap(bucket, root, 0)
+	}
+
+	if err == SkipMap {
+		return nil
+	}
+
+	return err
+}
+
+type walker struct {
+	opts WalkOptions
+	fn   WalkFunc
+	w    *writeTx
+}
+
+func (wk *walker) matches(path dbpath.Path) bool {
+	return wk.opts.Matcher == nil || wk.opts.Matcher.Matches(path)
+}
+
+func (wk *walker) visitMap(path dbpath.Path) error {
+	if !wk.matches(path) {
+		return nil
+	}
+	return wk.fn(path, true, nil)
+}
+
+// visitValue decodes the value stored under the path and passes a copy of it to fn.
+func (wk *walker) visitValue(path dbpath.Path, stored []byte) error {
+	if !wk.matches(path) {
+		return nil
+	}
+
+	value, err := wk.w.decodeValue(path, stored)
+	if err != nil {
+		return fmt.Errorf("while decoding value of %s: %w", path, err)
+	}
+
+	copyOfValue := make([]byte, len(value))
+	copy(copyOfValue, value)
+
+	return wk.fn(path, false, copyOfValue)
+}
+
+// visitBlob passes the content of the blob to fn. Blob chunks are not encoded.
+func (wk *walker) visitBlob(path dbpath.Path, blob storageBucket) error {
+	if !wk.matches(path) {
+		return nil
+	}
+	v, err := readBlob(blob)
+	if err != nil {
+		return fmt.Errorf("while reading blob %s: %w", path.String(), err)
+	}
+	return wk.fn(path, false, v)
+}
+
+func (wk *walker) walkMap(bucket storageBucket, path dbpath.Path, depth int) error {
+	if !wk.opts.PostOrder {
+		err := wk.visitMap(path)
+		if err == SkipMap {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if wk.opts.MaxDepth == 0 || depth < wk.opts.MaxDepth {
+		c := bucket.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			wk.w.checkForCancelledContext()
+			childPath := wk.w.childPath(path, k)
+			var err error
+			if v == nil {
+				child := bucket.Bucket(k)
+				if !isBlob(child) {
+					err = wk.walkMap(child, childPath, depth+1)
+					if err != nil {
+						return err
+					}
+					continue
+				}
+				err = wk.visitBlob(childPath, child)
+			} else {
+				err = wk.visitValue(childPath, v)
+			}
+			if err == SkipMap {
+				err = nil
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if wk.opts.PostOrder {
+		err := wk.visitMap(path)
+		if err != nil && err != SkipMap {
+			return err
+		}
+	}
+
+	return nil
+}
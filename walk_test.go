@@ -0,0 +1,167 @@
+package bolted_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/draganm/bolted"
+	"github.com/draganm/bolted/dbpath"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWalk(t *testing.T) {
+	bdb, cleanup := openEmptyDatabase(t, bolted.Options{})
+	defer cleanup()
+
+	err := bdb.Write(func(tx bolted.WriteTx) error {
+		tx.CreateMap(dbpath.ToPath("a"))
+		tx.Put(dbpath.ToPath("a", "x"), []byte("ax"))
+		tx.CreateMap(dbpath.ToPath("a", "b"))
+		tx.Put(dbpath.ToPath("a", "b", "y"), []byte("aby"))
+		tx.Put(dbpath.ToPath("z"), []byte("z"))
+		return nil
+	})
+	require.NoError(t, err)
+
+	walk := func(root dbpath.Path, opts bolted.WalkOptions, skip string) (visited []string, err error) {
+		visited = []string{}
+		err = bdb.Read(func(tx bolted.ReadTx) error {
+			return tx.WalkWithOptions(root, opts, func(path dbpath.Path, isMap bool, value []byte) error {
+				if isMap {
+					require.Nil(t, value)
+					visited = append(visited, path.String()+"/")
+				} else {
+					visited = append(visited, path.String()+"="+string(value))
+				}
+				if skip != "" && path.String() == skip {
+					return bolted.SkipMap
+				}
+				return nil
+			})
+		})
+		return visited, err
+	}
+
+	cases := []struct {
+		name     string
+		root     dbpath.Path
+		opts     bolted.WalkOptions
+		skip     string
+		expected []string
+	}{
+		{
+			name:     "pre order",
+			root:     dbpath.NilPath,
+			expected: []string{"/", "a/", "a/b/", "a/b/y=aby", "a/x=ax", "z=z"},
+		},
+		{
+			name:     "post order",
+			root:     dbpath.NilPath,
+			opts:     bolted.WalkOptions{PostOrder: true},
+			expected: []string{"a/b/y=aby", "a/b/", "a/x=ax", "a/", "z=z", "/"},
+		},
+		{
+			name:     "max depth",
+			root:     dbpath.NilPath,
+			opts:     bolted.WalkOptions{MaxDepth: 1},
+			expected: []string{"/", "a/", "z=z"},
+		},
+		{
+			name:     "sub tree",
+			root:     dbpath.ToPath("a"),
+			expected: []string{"a/", "a/b/", "a/b/y=aby", "a/x=ax"},
+		},
+		{
+			name:     "value as root",
+			root:     dbpath.ToPath("a", "x"),
+			expected: []string{"a/x=ax"},
+		},
+		{
+			name:     "skip map",
+			root:     dbpath.NilPath,
+			skip:     "a/b",
+			expected: []string{"/", "a/", "a/b/", "a/x=ax", "z=z"},
+		},
+		{
+			name:     "matcher",
+			root:     dbpath.NilPath,
+			opts:     bolted.WalkOptions{Matcher: dbpath.MustParseMatcher("**/{x,y}")},
+			expected: []string{"a/b/y=aby", "a/x=ax"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			visited, err := walk(tc.root, tc.opts, tc.skip)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, visited)
+		})
+	}
+
+	t.Run("error is returned", func(t *testing.T) {
+		stop := errors.New("stop")
+		count := 0
+		err := bdb.Read(func(tx bolted.ReadTx) error {
+			return tx.Walk(dbpath.NilPath, func(path dbpath.Path, isMap bool, value []byte) error {
+				count++
+				if count == 2 {
+					return stop
+				}
+				return nil
+			})
+		})
+		require.ErrorIs(t, err, stop)
+		require.Equal(t, 2, count)
+	})
+
+	t.Run("missing root", func(t *testing.T) {
+		err := bdb.Read(func(tx bolted.ReadTx) error {
+			return tx.Walk(dbpath.ToPath("missing"), func(path dbpath.Path, isMap bool, value []byte) error {
+				return nil
+			})
+		})
+		require.True(t, bolted.IsNotFound(err))
+	})
+}
+
+func TestWalkValuesLookingLikeFrames(t *testing.T) {
+	bdb, cleanup := openEmptyDatabase(t, bolted.Options{})
+	defer cleanup()
+
+	framed := []byte("\x00bolted\x00\x00payload")
+
+	err := bdb.Write(func(tx bolted.WriteTx) error {
+		tx.CreateMap(dbpath.ToPath("m"))
+		tx.Put(dbpath.ToPath("m", "v"), framed)
+		tx.PutReader(dbpath.ToPath("m", "blob"), bytes.NewReader(framed))
+		return nil
+	})
+	require.NoError(t, err)
+
+	cases := []struct {
+		name string
+		root dbpath.Path
+	}{
+		{name: "value root", root: dbpath.ToPath("m", "v")},
+		{name: "blob root", root: dbpath.ToPath("m", "blob")},
+		{name: "children", root: dbpath.ToPath("m")},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			values := 0
+			err := bdb.Read(func(tx bolted.ReadTx) error {
+				return tx.Walk(c.root, func(path dbpath.Path, isMap bool, value []byte) error {
+					if !isMap {
+						require.Equal(t, framed, value)
+						values++
+					}
+					return nil
+				})
+			})
+			require.NoError(t, err)
+			require.NotZero(t, values)
+		})
+	}
+}
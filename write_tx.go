@@ -2,6 +2,7 @@ package bolted
 
 import (
 	"context"
+	"crypto/cipher"
 	"errors"
 	"fmt"
 	"io"
@@ -12,13 +13,66 @@ import (
 	"go.etcd.io/bbolt"
 )
 
+// txOptions are derived from Options and shared by all transactions of a database.
+type txOptions struct {
+	pageTokens cipher.AEAD
+	// contentAddressed matches paths whose values are stored in the content store
+	contentAddressed dbpath.Matcher
+	// compressed matches paths whose values are compressed
+	compressed dbpath.Matcher
+	// encrypted matches paths whose values are encrypted
+	encrypted  dbpath.Matcher
+	encryption *valueEncryption
+	// pathEncryption encrypts elements of matching paths
+	pathEncryption *pathEncryption
+	quotas         []Quota
+	// audit enables recording of write transactions in the audit log
+	audit bool
+}
+
+func newTxOptions(options Options) (txOptions, error) {
+	pathEncryption, err := newPathEncryption(options.EncryptedPathElements, options.PathElementKey)
+	if err != nil {
+		return txOptions{}, fmt.Errorf("while initializing path element encryption: %w", err)
+	}
+
+	pageTokens, err := newPageTokenCipher(options.PageTokenKey)
+	if err != nil {
+		return txOptions{}, fmt.Errorf("while initializing page token encryption: %w", err)
+	}
+
+	return txOptions{
+		pageTokens:       pageTokens,
+		contentAddressed: options.ContentAddressed,
+		compressed:       options.Compressed,
+		encrypted:        options.Encrypted,
+		encryption:       newValueEncryption(options.KeyProvider),
+		pathEncryption:   pathEncryption,
+		quotas:           options.Quotas,
+		audit:            options.Audit,
+	}, nil
+}
+
 type writeTx struct {
-	btx         *bbolt.Tx
+	txOptions
+	btx         storageTx
 	readOnly    bool
-	rootBucket  *bbolt.Bucket
+	rootBucket  storageBucket
 	fillPercent float64
 	observer    *txObserver
 	ctx         context.Context
+	buckets     bucketCache
+	// rewritten are paths of values whose stored bytes were rewritten without a change of the value
+	rewritten []dbpath.Path
+}
+
+// beforeCommit is called once the function of a write transaction succeeded, before the transaction is committed.
+func (w *writeTx) beforeCommit() error {
+	err := w.recordAudit()
+	if err != nil {
+		return fmt.Errorf("while recording audit log: %w", err)
+	}
+	return nil
 }
 
 func (w *writeTx) checkForCancelledContext() {
@@ -56,24 +110,28 @@ func (w *writeTx) CreateMap(path dbpath.Path) {
 		raiseErrorForPath(path, "CreateMap", errors.New("root map already exists"))
 	}
 
-	var bucket = w.rootBucket
+	err := checkKey(path)
+	if err != nil {
+		raiseErrorForPath(path, "CreateMap", err)
+	}
 
-	if bucket == nil {
-		raiseErrorForPath(path, "CreateMap", errors.New("root bucket not found"))
+	bucket, err := w.bucket(path[:len(path)-1])
+	if err != nil {
+		raiseErrorForPath(path, "CreateMap", err)
 	}
 
-	for _, p := range path[:len(path)-1] {
-		bucket = bucket.Bucket([]byte(p))
-		if bucket == nil {
-			raiseErrorForPath(path, "CreateMap", errors.New("one of the parent buckets does not exist"))
+	last := w.storedKey(path)
+
+	if w.tracksQuotas() && bucket.Get(last) == nil && bucket.Bucket(last) == nil {
+		err = w.chargeQuota(path, quotaDelta{entries: 1})
+		if err != nil {
+			raiseErrorForPath(path, "CreateMap", err)
 		}
 	}
 
-	last := path[len(path)-1]
+	bucket.SetFillPercent(w.fillPercent)
 
-	bucket.FillPercent = w.fillPercent
-
-	_, err := bucket.CreateBucket([]byte(last))
+	_, err = bucket.CreateBucket(last)
 
 	if err != nil {
 		raiseErrorForPath(path, "CreateMap", err)
@@ -88,142 +146,207 @@ func (w *writeTx) CreateMap(path dbpath.Path) {
 func (w *writeTx) Delete(path dbpath.Path) {
 	w.checkForCancelledContext()
 
-	if len(path) == 0 {
-		raiseErrorForPath(path, "Delete", errors.New("root cannot be deleted"))
+	err := w.delete(path)
+	if err != nil {
+		raiseErrorForPath(path, "Delete", err)
 	}
 
-	var bucket = w.rootBucket
+	w.observer.delete(path)
 
-	if bucket == nil {
-		raiseErrorForPath(path, "Delete", errors.New("root bucket not found"))
-	}
+}
 
-	for _, p := range path[:len(path)-1] {
-		bucket = bucket.Bucket([]byte(p))
-		if bucket == nil {
-			raiseErrorForPath(path, "Delete", errors.New("one of the parent buckets does not exist"))
-		}
+func (w *writeTx) delete(path dbpath.Path) error {
+	if len(path) == 0 {
+		return errors.New("root cannot be deleted")
 	}
 
-	last := []byte(path[len(path)-1])
+	bucket, err := w.bucket(path[:len(path)-1])
+	if err != nil {
+		return err
+	}
 
-	bucket.FillPercent = w.fillPercent
+	last := w.storedKey(path)
 
-	countDownSize := func() {
-		size := bucket.Sequence()
-		if size == 0 {
-			raiseErrorForPath(path, "Delete", errors.New("successful deletion from empty sequence - this should never happen"))
-		}
-		size--
-		bucket.SetSequence(size)
+	bucket.SetFillPercent(w.fillPercent)
 
+	err = w.chargeQuotaForDelete(bucket, path, last)
+	if err != nil {
+		return err
 	}
 
 	val := bucket.Get(last)
 	if val != nil {
-		err := bucket.Delete(last)
+		err = w.releaseValue(val)
 		if err != nil {
-			raiseErrorForPath(path, "Delete", err)
+			return err
+		}
+		err = bucket.Delete(last)
+		if err != nil {
+			return err
+		}
+	} else {
+		b := bucket.Bucket(last)
+		if b == nil {
+			return ErrNotFound
 		}
-		countDownSize()
-		w.observer.delete(path)
-		return
-	}
 
-	b := bucket.Bucket(last)
-	if b == nil {
-		raiseErrorForPath(path, "Delete", ErrNotFound)
-	}
+		err = w.releaseMap(b)
+		if err != nil {
+			return err
+		}
 
-	err := bucket.DeleteBucket(last)
+		w.buckets.invalidate(path)
 
-	if err != nil {
-		raiseErrorForPath(path, "Delete", err)
+		err = bucket.DeleteBucket(last)
+		if err != nil {
+			return err
+		}
 	}
 
-	countDownSize()
-
-	w.observer.delete(path)
+	size := bucket.Sequence()
+	if size == 0 {
+		return errors.New("successful deletion from empty sequence - this should never happen")
+	}
+	bucket.SetSequence(size - 1)
 
+	return nil
 }
 
 func (w *writeTx) Put(path dbpath.Path, value []byte) {
 	w.checkForCancelledContext()
 
+	err := w.put(path, value)
+	if err != nil {
+		raiseErrorForPath(path, "Put", err)
+	}
+
+	w.observer.put(path)
+
+}
+
+func (w *writeTx) put(path dbpath.Path, value []byte) error {
 	if len(path) == 0 {
-		raiseErrorForPath(path, "Put", errors.New("value cannot be put as root"))
+		return errors.New("value cannot be put as root")
 	}
 
-	var bucket = w.rootBucket
+	err := checkKey(path)
+	if err != nil {
+		return err
+	}
 
-	if bucket == nil {
-		raiseErrorForPath(path, "Put", errors.New("root bucket not found"))
+	bucket, err := w.bucket(path[:len(path)-1])
+	if err != nil {
+		return err
 	}
 
-	for _, p := range path[:len(path)-1] {
-		bucket = bucket.Bucket([]byte(p))
-		if bucket == nil {
-			raiseErrorForPath(path, "Put", errors.New("one of the parent buckets does not exist"))
-		}
+	last := w.storedKey(path)
+
+	err = w.chargeQuotaForPut(bucket, path, last, uint64(len(value)))
+	if err != nil {
+		return err
 	}
 
-	last := path[len(path)-1]
+	old := bucket.Get(last)
+	exists := old != nil
 
-	exists := bucket.Get([]byte(last)) != nil
+	bucket.SetFillPercent(w.fillPercent)
 
-	bucket.FillPercent = w.fillPercent
+	if exists {
+		err = w.releaseValue(old)
+	} else {
+		exists, err = deleteBlob(bucket, last)
+	}
 
-	err := bucket.Put([]byte(last), value)
+	if err != nil {
+		return err
+	}
+
+	value, err = w.encodeValue(path, value)
+	if err != nil {
+		return err
+	}
+
+	err = bucket.Put(last, value)
 
 	if err == bbolt.ErrIncompatibleValue {
-		raiseErrorForPath(path, "Put", ErrConflict)
+		return ErrConflict
 	}
 
 	if err != nil {
-		raiseErrorForPath(path, "Put", err)
+		return err
 	}
 
 	if !exists {
 		bucket.NextSequence()
 	}
 
-	w.observer.put(path)
-
+	return nil
 }
 
-func (w *writeTx) Get(path dbpath.Path) (v []byte) {
+func (w *writeTx) Get(path dbpath.Path) []byte {
 	w.checkForCancelledContext()
 
-	if len(path) == 0 {
-		raiseErrorForPath(path, "Get", errors.New("cannot get value of root"))
+	v, err := w.getRaw(path)
+	if err != nil {
+		raiseErrorForPath(path, "Get", err)
 	}
 
-	var bucket = w.rootBucket
+	copyOfValue := make([]byte, len(v))
+	copy(copyOfValue, v)
 
-	if bucket == nil {
-		raiseErrorForPath(path, "Get", errors.New("root bucket not found"))
+	return copyOfValue
+}
+
+// View calls fn with the value stored under the path without copying it.
+// The slice passed to fn is only valid during the call and must not be modified.
+// Blobs are assembled into a new slice before calling fn.
+func (w *writeTx) View(path dbpath.Path, fn func(v []byte) error) error {
+	w.checkForCancelledContext()
+
+	v, err := w.getRaw(path)
+	if err != nil {
+		raiseErrorForPath(path, "View", err)
 	}
 
-	for _, p := range path[:len(path)-1] {
-		bucket = bucket.Bucket([]byte(p))
-		if bucket == nil {
-			raiseErrorForPath(path, "Get", errors.New("one of the parent buckets does not exist"))
-		}
+	return fn(v)
+}
+
+// GetInto appends the value stored under the path to buf[:0] and returns the result,
+// allowing the caller to reuse buf between calls.
+func (w *writeTx) GetInto(path dbpath.Path, buf []byte) []byte {
+	w.checkForCancelledContext()
+
+	v, err := w.getRaw(path)
+	if err != nil {
+		raiseErrorForPath(path, "GetInto", err)
 	}
 
-	last := path[len(path)-1]
+	return append(buf[:0], v...)
+}
 
-	v = bucket.Get([]byte(last))
+func (w *writeTx) getRaw(path dbpath.Path) ([]byte, error) {
+	if len(path) == 0 {
+		return nil, errors.New("cannot get value of root")
+	}
 
-	if v == nil {
-		raiseErrorForPath(path, "Get", errors.New("value not found"))
+	bucket, err := w.bucket(path[:len(path)-1])
+	if err != nil {
+		return nil, err
 	}
 
-	copyOfValue := make([]byte, len(v))
-	copy(copyOfValue, v)
+	last := w.storedKey(path)
 
-	return copyOfValue
+	v := bucket.Get(last)
+
+	if v == nil {
+		blob := bucket.Bucket(last)
+		if isBlob(blob) {
+			return readBlob(blob)
+		}
+		return nil, errors.New("value not found")
+	}
 
+	return w.decodeValue(path, v)
 }
 
 func (w *writeTx) ID() uint64 {
@@ -232,33 +355,70 @@ func (w *writeTx) ID() uint64 {
 
 func (w *writeTx) Iterate(path dbpath.Path) (it Iterator) {
 	w.checkForCancelledContext()
+	it, err := w.newIterator(path, Unbounded(), Unbounded(), false)
+	if err != nil {
+		raiseErrorForPath(path, "Iterate", err)
+	}
+	return it
+}
 
-	var bucket = w.rootBucket
+func (w *writeTx) IterateRange(path dbpath.Path, from, to Bound) (it Iterator) {
+	w.checkForCancelledContext()
+	it, err := w.newIterator(path, from, to, false)
+	if err != nil {
+		raiseErrorForPath(path, "IterateRange", err)
+	}
+	return it
+}
 
-	if bucket == nil {
-		raiseErrorForPath(path, "Iterate", errors.New("root bucket not found"))
+func (w *writeTx) IterateRangeReverse(path dbpath.Path, from, to Bound) (it Iterator) {
+	w.checkForCancelledContext()
+	it, err := w.newIterator(path, from, to, true)
+	if err != nil {
+		raiseErrorForPath(path, "IterateRangeReverse", err)
 	}
+	return it
+}
 
-	for _, p := range path {
-		bucket = bucket.Bucket([]byte(p))
-		if bucket == nil {
-			raiseErrorForPath(path, "Iterate", errors.New("one of the parent buckets does not exist"))
-		}
+func (w *writeTx) IteratePrefix(path dbpath.Path, prefix string) (it Iterator) {
+	w.checkForCancelledContext()
+	from, to := prefixBounds(prefix)
+	it, err := w.newIterator(path, from, to, false)
+	if err != nil {
+		raiseErrorForPath(path, "IteratePrefix", err)
 	}
+	return it
+}
 
-	c := bucket.Cursor()
-	k, v := c.First()
+func (w *writeTx) IteratePrefixReverse(path dbpath.Path, prefix string) (it Iterator) {
+	w.checkForCancelledContext()
+	from, to := prefixBounds(prefix)
+	it, err := w.newIterator(path, from, to, true)
+	if err != nil {
+		raiseErrorForPath(path, "IteratePrefixReverse", err)
+	}
+	return it
+}
 
-	copyOfValue := make([]byte, len(v))
-	copy(copyOfValue, v)
+func (w *writeTx) newIterator(path dbpath.Path, from, to Bound, reverse bool) (*iterator, error) {
+	bucket, err := w.bucket(path)
+	if err != nil {
+		return nil, err
+	}
 
-	return &iterator{
-		c:     c,
-		key:   string(k),
-		value: copyOfValue,
-		done:  k == nil,
-		ctx:   w.ctx,
+	it := &iterator{
+		c:       bucket.Cursor(),
+		tx:      w,
+		path:    path,
+		ctx:     w.ctx,
+		lower:   from,
+		upper:   to,
+		reverse: reverse,
 	}
+
+	it.First()
+
+	return it, nil
 }
 
 func (w *writeTx) Exists(path dbpath.Path) (ex bool) {
@@ -269,28 +429,24 @@ func (w *writeTx) Exists(path dbpath.Path) (ex bool) {
 		return true
 	}
 
-	var bucket = w.rootBucket
-
-	if bucket == nil {
-		raiseErrorForPath(path, "Exists", errors.New("root bucket not found"))
+	bucket, err := w.bucket(path[:len(path)-1])
+	if err == errParentBucketNotFound {
+		return false
 	}
 
-	for _, p := range path[:len(path)-1] {
-		bucket = bucket.Bucket([]byte(p))
-		if bucket == nil {
-			return false
-		}
+	if err != nil {
+		raiseErrorForPath(path, "Exists", err)
 	}
 
-	last := path[len(path)-1]
+	last := w.storedKey(path)
 
-	v := bucket.Get([]byte(last))
+	v := bucket.Get(last)
 
 	if v != nil {
 		return true
 	}
 
-	return bucket.Bucket([]byte(last)) != nil
+	return bucket.Bucket(last) != nil
 
 }
 
@@ -302,65 +458,63 @@ func (w *writeTx) IsMap(path dbpath.Path) (ism bool) {
 		return true
 	}
 
-	var bucket = w.rootBucket
-
-	if bucket == nil {
-		raiseErrorForPath(path, "IsMap", errors.New("root bucket not found"))
-	}
-
-	for _, p := range path[:len(path)-1] {
-		bucket = bucket.Bucket([]byte(p))
-		if bucket == nil {
-			raiseErrorForPath(path, "IsMap", errors.New("one of the parent buckets does not exist"))
-		}
+	bucket, err := w.bucket(path[:len(path)-1])
+	if err != nil {
+		raiseErrorForPath(path, "IsMap", err)
 	}
 
-	last := path[len(path)-1]
+	last := w.storedKey(path)
 
-	v := bucket.Get([]byte(last))
+	v := bucket.Get(last)
 
 	if v != nil {
 		return false
 	}
 
-	return bucket.Bucket([]byte(last)) != nil
+	b := bucket.Bucket(last)
+
+	return b != nil && !isBlob(b)
 
 }
 
 func (w *writeTx) GetSizeOf(path dbpath.Path) (s uint64) {
 	w.checkForCancelledContext()
 
-	var bucket = w.rootBucket
-
-	if bucket == nil {
-		raiseErrorForPath(path, "GetSizeOf", errors.New("root bucket not found"))
-	}
-
 	if len(path) == 0 {
+		bucket, err := w.bucket(path)
+		if err != nil {
+			raiseErrorForPath(path, "GetSizeOf", err)
+		}
 		return bucket.Sequence()
 	}
 
-	for _, p := range path[:len(path)-1] {
-		bucket = bucket.Bucket([]byte(p))
-		if bucket == nil {
-			raiseErrorForPath(path, "GetSizeOf", errors.New("one of the parent buckets does not exist"))
-		}
+	bucket, err := w.bucket(path[:len(path)-1])
+	if err != nil {
+		raiseErrorForPath(path, "GetSizeOf", err)
 	}
 
-	last := path[len(path)-1]
+	last := w.storedKey(path)
 
-	v := bucket.Get([]byte(last))
+	v := bucket.Get(last)
 
 	if v != nil {
-		return uint64(len(v))
+		s, err = w.decodedSize(path, v)
+		if err != nil {
+			raiseErrorForPath(path, "GetSizeOf", err)
+		}
+		return s
 	}
 
-	bucket = bucket.Bucket([]byte(last))
+	bucket = bucket.Bucket(last)
 
 	if bucket == nil {
 		raiseErrorForPath(path, "GetSizeOf", errors.New("does not exist"))
 	}
 
+	if isBlob(bucket) {
+		return blobSize(bucket)
+	}
+
 	return bucket.Sequence()
 
 }